@@ -0,0 +1,368 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+)
+
+var adminTpl = template.Must(template.New("admin").Parse(adminHTML))
+
+func (a *api) admin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := adminTpl.Execute(w, a.service.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+const adminHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>qlfu admin</title>
+<style>
+body{margin:0;font:14px/1.4 -apple-system,Helvetica,Arial,sans-serif;color:#222}
+header{background:#333;color:#fff;padding:8px 16px}
+header a{color:#ddd;margin-right:16px;text-decoration:none;cursor:pointer}
+header a.active{color:#fff;font-weight:bold}
+section{padding:16px;display:none}
+section.active{display:block}
+#diagram{position:relative;min-height:200px}
+#diagram svg{position:absolute;left:0;top:0;pointer-events:none}
+.table{position:absolute;border:1px solid #888;border-radius:4px;background:#fff;min-width:180px;font-family:monospace}
+.table h3{margin:0;padding:4px 8px;background:#eee;font-size:13px}
+.table div{padding:1px 8px;display:flex;justify-content:space-between}
+.table div i{color:#777;margin-left:16px}
+.table .required{font-weight:bold}
+.cols{display:flex;gap:16px}
+.cols>div{flex:1}
+textarea{width:100%;height:320px;font-family:monospace}
+pre{background:#f8f8f8;border:1px solid #eee;padding:8px;overflow:auto;min-height:40px}
+table.rows{border-collapse:collapse;margin-top:8px}
+table.rows td,table.rows th{border:1px solid #ddd;padding:2px 6px;font-family:monospace}
+table.rows input{font-family:monospace;border:0;background:transparent;width:100%}
+table.rows tr.filters input{background:#fffbe6}
+.error{color:#c33}
+</style>
+</head>
+<body>
+<header>
+<a data-tab="schema" class="active">schema</a>
+<a data-tab="designer">designer</a>
+<a data-tab="data">data</a>
+</header>
+<section id="schema" class="active">
+<div id="diagram"></div>
+<h4>GET /schema</h4>
+<pre id="ql"></pre>
+</section>
+<section id="designer">
+<p>
+<select id="format">
+<option value="application/json">json sample</option>
+<option value="application/schema+json">json schema</option>
+<option value="text/plain">ql</option>
+</select>
+<button id="preview">preview migration</button>
+<button id="apply">apply</button>
+<span id="designer-status"></span>
+</p>
+<div class="cols">
+<div><textarea id="sample"></textarea></div>
+<div><pre id="migration"></pre></div>
+</div>
+</section>
+<section id="data">
+<p>
+<select id="model"></select>
+page size <select id="size"><option>10</option><option selected>25</option><option>50</option><option>100</option></select>
+<button id="prev">prev</button> <span id="page"></span> <button id="next">next</button>
+<button id="add">new row</button>
+<span id="data-status"></span>
+</p>
+<table class="rows" id="rows"></table>
+</section>
+<script>
+var version = {{.}};
+var schemas = {};
+var state = {model: "", offset: 0, filters: {}, total: 0};
+
+function el(tag, attrs, children) {
+  var e = document.createElement(tag);
+  for (var k in attrs || {}) {
+    if (k === "text") { e.textContent = attrs[k]; } else { e.setAttribute(k, attrs[k]); }
+  }
+  (children || []).forEach(function (c) { if (c) { e.appendChild(c); } });
+  return e;
+}
+
+function request(method, url, body, type) {
+  var opts = {method: method, headers: {}};
+  if (body !== undefined) {
+    opts.body = body;
+    opts.headers["Content-Type"] = type || "application/json";
+  }
+  return fetch(url, opts).then(function (res) {
+    return res.text().then(function (txt) {
+      var data = txt;
+      try { data = JSON.parse(txt); } catch (err) {}
+      if (!res.ok && res.status !== 404) {
+        throw new Error(res.status + " " + (data.error || txt));
+      }
+      return {status: res.status, data: data};
+    });
+  });
+}
+
+function typeOf(prop) {
+  if (prop.$ref) { return "-> " + prop.$ref.split("/").pop(); }
+  var t = Array.isArray(prop.type) ? prop.type[0] : prop.type;
+  return prop.format || (prop.contentEncoding ? "blob" : t);
+}
+
+function drawSchema() {
+  var d = document.getElementById("diagram");
+  d.innerHTML = "";
+  var names = Object.keys(schemas);
+  var boxes = {};
+  names.forEach(function (name, i) {
+    var s = schemas[name];
+    var required = s.required || [];
+    var rows = Object.keys(s.properties).map(function (p) {
+      return el("div", {"class": required.indexOf(p) >= 0 ? "required" : ""}, [
+        el("span", {text: p}), el("i", {text: typeOf(s.properties[p])})
+      ]);
+    });
+    var box = el("div", {"class": "table", style: "left:" + (20 + (i % 4) * 260) + "px;top:" + (20 + Math.floor(i / 4) * 260) + "px"}, [el("h3", {text: name})].concat(rows));
+    d.appendChild(box);
+    boxes[name] = box;
+  });
+  d.style.height = (Math.ceil(names.length / 4) * 260 + 20) + "px";
+  var ns = "http://www.w3.org/2000/svg";
+  var svg = document.createElementNS(ns, "svg");
+  svg.setAttribute("width", d.scrollWidth);
+  svg.setAttribute("height", d.scrollHeight);
+  svg.innerHTML = '<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M0,0L10,5L0,10z" fill="#c67b00"/></marker></defs>';
+  names.forEach(function (name) {
+    var props = schemas[name].properties;
+    Object.keys(props).forEach(function (p) {
+      if (!props[p].$ref) { return; }
+      var dest = boxes[props[p].$ref.split("/").pop()];
+      var src = boxes[name];
+      if (!dest || dest === src) { return; }
+      var line = document.createElementNS(ns, "line");
+      line.setAttribute("x1", src.offsetLeft + src.offsetWidth / 2);
+      line.setAttribute("y1", src.offsetTop + src.offsetHeight / 2);
+      var x2 = dest.offsetLeft + dest.offsetWidth / 2;
+      var y2 = dest.offsetTop + dest.offsetHeight / 2;
+      line.setAttribute("x2", x2 < src.offsetLeft ? dest.offsetLeft + dest.offsetWidth : x2 > src.offsetLeft + src.offsetWidth ? dest.offsetLeft : x2);
+      line.setAttribute("y2", y2 < src.offsetTop ? dest.offsetTop + dest.offsetHeight : y2 > src.offsetTop + src.offsetHeight ? dest.offsetTop : y2);
+      line.setAttribute("stroke", "#c67b00");
+      line.setAttribute("marker-end", "url(#arrow)");
+      svg.appendChild(line);
+    });
+  });
+  d.appendChild(svg);
+}
+
+function sampleFor() {
+  var o = {};
+  Object.keys(schemas).forEach(function (name) {
+    var s = schemas[name];
+    if (Object.keys(schemas).some(function (n) {
+      var p = schemas[n].properties;
+      return Object.keys(p).some(function (k) { return p[k].$ref && p[k].$ref.split("/").pop() === name; });
+    })) { return; }
+    o[s.title || name] = sampleObject(s);
+  });
+  return o;
+}
+
+function sampleObject(s, seen) {
+  var o = {};
+  seen = (seen || []).concat([s]);
+  Object.keys(s.properties).forEach(function (p) {
+    var prop = s.properties[p];
+    if (p === "id" || /_id$/.test(p) || prop.readOnly) { return; }
+    if (prop.$ref) {
+      var d = schemas[prop.$ref.split("/").pop()];
+      if (d && seen.indexOf(d) < 0) { o[p] = sampleObject(d, seen); }
+      return;
+    }
+    var t = typeOf(prop);
+    o[p] = t === "boolean" ? true : t === "integer" || t === "number" ? 1 : t === "date-time" ? "Mon Jan 2 15:04:05 2006" : p;
+  });
+  return o;
+}
+
+function load() {
+  return Promise.all([
+    request("GET", "/schema"),
+    request("GET", "/v" + version + "/_schemas")
+  ]).then(function (res) {
+    document.getElementById("ql").textContent = res[0].data;
+    schemas = (res[1].data && res[1].data.$defs) || {};
+    drawSchema();
+    var sample = document.getElementById("sample");
+    if (!sample.value) { sample.value = JSON.stringify(sampleFor(), null, 2); }
+    var sel = document.getElementById("model");
+    sel.innerHTML = "";
+    Object.keys(schemas).forEach(function (name) { sel.appendChild(el("option", {text: name})); });
+    if (!schemas[state.model]) { state.model = sel.value; state.offset = 0; state.filters = {}; }
+    sel.value = state.model;
+    return browse();
+  });
+}
+
+function postSchema(dryRun) {
+  var status = document.getElementById("designer-status");
+  status.textContent = "";
+  status.className = "";
+  return request("POST", "/schema" + (dryRun ? "?dry_run=true" : ""), document.getElementById("sample").value, document.getElementById("format").value)
+    .then(function (res) {
+      if (dryRun) {
+        document.getElementById("migration").textContent = res.data;
+        return;
+      }
+      status.textContent = "applied";
+      return load();
+    }).catch(function (err) {
+      status.textContent = err.message;
+      status.className = "error";
+    });
+}
+
+function columns() {
+  var s = schemas[state.model];
+  return s ? Object.keys(s.properties).filter(function (p) { return !s.properties[p].$ref; }) : [];
+}
+
+function query() {
+  var q = [];
+  Object.keys(state.filters).forEach(function (k) {
+    if (state.filters[k] !== "") { q.push(encodeURIComponent(k) + "=" + encodeURIComponent(state.filters[k])); }
+  });
+  return q;
+}
+
+function value(prop, v) {
+  if (v === "") { return undefined; }
+  var t = typeOf(prop);
+  if (t === "integer" || t === "number") { return Number(v); }
+  if (t === "boolean") { return v === "true"; }
+  return v;
+}
+
+function rowPayload(tr) {
+  var s = schemas[state.model];
+  var o = {};
+  tr.querySelectorAll("input[data-col]").forEach(function (i) {
+    var c = i.getAttribute("data-col");
+    if (s.properties[c].readOnly || i.value === i.getAttribute("data-orig")) { return; }
+    var v = value(s.properties[c], i.value);
+    if (v !== undefined) { o[c] = v; }
+  });
+  return o;
+}
+
+function showStatus(msg, error) {
+  var status = document.getElementById("data-status");
+  status.textContent = msg;
+  status.className = error ? "error" : "";
+}
+
+function browse() {
+  var table = document.getElementById("rows");
+  table.innerHTML = "";
+  if (!state.model) { return Promise.resolve(); }
+  var cols = columns();
+  var size = Number(document.getElementById("size").value);
+  var base = "/v" + version + "/" + state.model;
+  var q = query();
+  var page = q.concat(["_limit=" + size, "_offset=" + state.offset]);
+  return Promise.all([
+    request("GET", base + "?" + page.join("&")),
+    request("GET", base + "/_count" + (q.length ? "?" + q.join("&") : ""))
+  ]).then(function (res) {
+    var rows = Array.isArray(res[0].data) ? res[0].data : [];
+    state.total = res[1].data.count || 0;
+    document.getElementById("page").textContent = (state.total ? state.offset + 1 : 0) + "-" + (state.offset + rows.length) + " of " + state.total;
+    table.appendChild(el("tr", {}, cols.map(function (c) { return el("th", {text: c}); }).concat([el("th")])));
+    table.appendChild(el("tr", {"class": "filters"}, cols.map(function (c) {
+      var i = el("input", {placeholder: "filter", value: state.filters[c] || ""});
+      i.addEventListener("change", function () {
+        state.filters[c] = i.value;
+        state.offset = 0;
+        browse().catch(function (err) { showStatus(err.message, true); });
+      });
+      return el("td", {}, [i]);
+    }).concat([el("td")])));
+    rows.forEach(function (row) { table.appendChild(rowView(cols, row)); });
+  });
+}
+
+function rowView(cols, row) {
+  var tr = el("tr", {}, cols.map(function (c) {
+    var v = row[c] === null || row[c] === undefined ? "" : String(row[c]);
+    return el("td", {}, [el("input", {"data-col": c, "data-orig": v, value: v})]);
+  }));
+  var base = "/v" + version + "/" + state.model;
+  var save = el("button", {text: row.id === undefined ? "create" : "save"});
+  save.addEventListener("click", function () {
+    var p = row.id === undefined ? request("POST", base, JSON.stringify(rowPayload(tr))) : request("PUT", base + "/" + row.id, JSON.stringify(rowPayload(tr)));
+    p.then(function () { showStatus("saved"); return browse(); }).catch(function (err) { showStatus(err.message, true); });
+  });
+  var actions = [save];
+  if (row.id !== undefined) {
+    var del = el("button", {text: "delete"});
+    del.addEventListener("click", function () {
+      if (!confirm("delete " + state.model + " " + row.id + "?")) { return; }
+      request("DELETE", base + "/" + row.id).then(function () { showStatus("deleted"); return browse(); }).catch(function (err) { showStatus(err.message, true); });
+    });
+    actions.push(del);
+  }
+  tr.appendChild(el("td", {}, actions));
+  return tr;
+}
+
+document.querySelectorAll("header a").forEach(function (a) {
+  a.addEventListener("click", function () {
+    document.querySelectorAll("header a").forEach(function (b) { b.className = b === a ? "active" : ""; });
+    document.querySelectorAll("section").forEach(function (s) { s.className = s.id === a.getAttribute("data-tab") ? "active" : ""; });
+    if (a.getAttribute("data-tab") === "schema") { drawSchema(); }
+  });
+});
+document.getElementById("preview").addEventListener("click", function () { postSchema(true); });
+document.getElementById("apply").addEventListener("click", function () {
+  if (confirm("applying a schema starts a new empty database, continue?")) { postSchema(false); }
+});
+document.getElementById("model").addEventListener("change", function (e) {
+  state.model = e.target.value;
+  state.offset = 0;
+  state.filters = {};
+  browse().catch(function (err) { showStatus(err.message, true); });
+});
+document.getElementById("size").addEventListener("change", function () {
+  state.offset = 0;
+  browse().catch(function (err) { showStatus(err.message, true); });
+});
+document.getElementById("prev").addEventListener("click", function () {
+  state.offset = Math.max(0, state.offset - Number(document.getElementById("size").value));
+  browse().catch(function (err) { showStatus(err.message, true); });
+});
+document.getElementById("next").addEventListener("click", function () {
+  var size = Number(document.getElementById("size").value);
+  if (state.offset + size >= state.total) { return; }
+  state.offset += size;
+  browse().catch(function (err) { showStatus(err.message, true); });
+});
+document.getElementById("add").addEventListener("click", function () {
+  var table = document.getElementById("rows");
+  if (!state.model) { return; }
+  table.insertBefore(rowView(columns(), {}), table.children[2] || null);
+});
+load().catch(function (err) { showStatus(err.message, true); });
+</script>
+</body>
+</html>
+`
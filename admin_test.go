@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_admin(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/admin", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `var version = "1";`) {
+		t.Fatalf("expected the admin page got %d %s", w.Code, w.Body)
+	}
+
+	src := `{"user":{"username":"gernest"}}`
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema?dry_run=true", strings.NewReader(src)))
+	if !strings.Contains(w.Body.String(), "create table users") {
+		t.Errorf("expected a migration preview got %s", w.Body)
+	}
+	if len(a.c.models()) != 0 {
+		t.Fatal("expected dry run to leave the schema alone")
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(src)))
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		w = httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{"username":"`+name+`"}`)))
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/users?limit=2&offset=1", nil))
+	var rows []modelProps
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["username"] != "d" || rows[1]["username"] != "c" {
+		t.Errorf("expected the second page of two got %v", rows)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/users?limit=-1", nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/cznic/ql"
+)
+
+var aggregateFuncs = []string{"sum", "avg", "min", "max"}
+
+type aggregateQuery struct {
+	groupBy []string
+	funcs   map[string][]string
+}
+
+func (c *crud) count(model string, q *listQuery) (int64, error) {
+	where, args := c.where(model, q)
+	ctx := make(map[string]interface{})
+	ctx["model"] = model
+	ctx["where"] = where
+	var buf bytes.Buffer
+	err := tpl.ExecuteTemplate(&buf, "count", ctx)
+	if err != nil {
+		return 0, err
+	}
+	rs, _, err := c.db.Run(ql.NewRWCtx(), buf.String(), args...)
+	if err != nil {
+		return 0, err
+	}
+	o, err := collect(rs)
+	if err != nil {
+		return 0, err
+	}
+	if len(o) == 0 {
+		return 0, nil
+	}
+	n, _ := o[0]["count"].(int64)
+	return n, nil
+}
+
+func (c *crud) aggregate(model string, a *aggregateQuery, q *listQuery) ([]modelProps, error) {
+	t, ok := c.schema.tables[model]
+	if !ok {
+		return nil, errNotFound
+	}
+	verr := &validationError{}
+	fields := append([]string{}, a.groupBy...)
+	for _, g := range a.groupBy {
+		if _, ok := t.colID(g); !ok {
+			verr.add("group_by", "unknown column "+g)
+		}
+	}
+	fields = append(fields, "count(*) AS count")
+	for _, fn := range aggregateFuncs {
+		for _, col := range a.funcs[fn] {
+			idx, ok := t.colID(col)
+			if !ok {
+				verr.add(fn, "unknown column "+col)
+				continue
+			}
+			if (fn == "sum" || fn == "avg") && !numeric(t.columns[idx].typ) {
+				verr.add(fn, col+" is not a numeric column")
+				continue
+			}
+			fields = append(fields, fn+"("+col+") AS "+fn+"_"+col)
+		}
+	}
+	if verr.failed() {
+		return nil, verr
+	}
+	where, args := c.where(model, q)
+	ctx := make(map[string]interface{})
+	ctx["model"] = model
+	ctx["where"] = where
+	ctx["fields"] = fields
+	ctx["group"] = a.groupBy
+	var buf bytes.Buffer
+	err := tpl.ExecuteTemplate(&buf, "aggregate", ctx)
+	if err != nil {
+		return nil, err
+	}
+	rs, _, err := c.db.Run(ql.NewRWCtx(), buf.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return collect(rs)
+}
+
+func numeric(typ ql.Type) bool {
+	switch typ {
+	case ql.Int8, ql.Int16, ql.Int32, ql.Int64,
+		ql.Uint8, ql.Uint16, ql.Uint32, ql.Uint64,
+		ql.Float32, ql.Float64, ql.Complex64, ql.Complex128,
+		ql.BigInt, ql.BigRat, ql.Duration:
+		return true
+	}
+	return false
+}
+
+func splitList(src string) []string {
+	var o []string
+	for _, v := range strings.Split(src, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			o = append(o, v)
+		}
+	}
+	return o
+}
+
+func aggregateQueryFrom(t *table, r *http.Request) *aggregateQuery {
+	v := r.URL.Query()
+	a := &aggregateQuery{
+		groupBy: splitList(controlParam(t, v, "group_by")),
+		funcs:   make(map[string][]string),
+	}
+	for _, fn := range aggregateFuncs {
+		a.funcs[fn] = splitList(controlParam(t, v, fn))
+	}
+	return a
+}
+
+func aggregateParams(t *table) []param {
+	o := []param{
+		{
+			Name: controlName(t, "group_by"),
+			Type: "string",
+			Desc: "comma separated columns to group " + t.name + " objects by",
+		},
+	}
+	for _, fn := range aggregateFuncs {
+		o = append(o, param{
+			Name: controlName(t, fn),
+			Type: "string",
+			Desc: "comma separated columns to compute " + fn + " of",
+		})
+	}
+	return o
+}
+
+func (c *crud) countHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := c.listQueryFrom(model, r, false)
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		n, err := c.count(model, q)
+		if err != nil {
+			jsonErr(w, err, http.StatusInternalServerError)
+			return
+		}
+		jsonRes(w, map[string]int64{"count": n})
+	}
+}
+
+func (c *crud) aggregateHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := c.listQueryFrom(model, r, false)
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		o, err := c.aggregate(model, aggregateQueryFrom(c.schema.tables[model], r), q)
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		jsonRes(w, o)
+	}
+}
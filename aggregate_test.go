@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cznic/ql"
+)
+
+func TestCRUD_aggregate(t *testing.T) {
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	_, _, err = db.Run(ql.NewRWCtx(), `
+	begin transaction;
+		create table orders(
+			id int64,
+			country string,
+			amount float64,
+		);
+	commit;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample := []modelProps{
+		{"country": "Tanzania", "amount": 10.0},
+		{"country": "Tanzania", "amount": 20.0},
+		{"country": "Kenya", "amount": 5.0},
+	}
+	for _, v := range sample {
+		_, err = c.create("orders", v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	q, err := c.listQueryFrom("orders", httptest.NewRequest("GET", "/orders/_count?country=Tanzania", nil), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.listQueryFrom("orders", httptest.NewRequest("GET", "/orders/_count?limit=1", nil), false)
+	if err != errPaging || crudErrCode(err) != http.StatusBadRequest {
+		t.Errorf("expected a bad request for paged counts got %v", err)
+	}
+	n, err := c.count("orders", q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+	n, err = c.count("orders", &listQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 got %d", n)
+	}
+
+	req := httptest.NewRequest("GET", "/orders/_aggregate?group_by=country&sum=amount&max=amount", nil)
+	o, err := c.aggregate("orders", aggregateQueryFrom(c.schema.tables["orders"], req), &listQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 2 {
+		t.Fatalf("expected 2 groups got %d", len(o))
+	}
+	if o[1]["country"] != "Tanzania" || o[1]["sum_amount"] != 30.0 || o[1]["count"] != int64(2) {
+		t.Errorf("unexpected group %v", o[1])
+	}
+	if o[0]["max_amount"] != 5.0 {
+		t.Errorf("expected 5 got %v", o[0]["max_amount"])
+	}
+
+	req = httptest.NewRequest("GET", "/orders/_aggregate?group_by=missing&avg=country", nil)
+	_, err = c.aggregate("orders", aggregateQueryFrom(c.schema.tables["orders"], req), &listQuery{})
+	v, ok := err.(*validationError)
+	if !ok {
+		t.Fatalf("expected validation error got %v", err)
+	}
+	if len(v.fields) != 2 {
+		t.Errorf("expected 2 failing fields got %v", v.fields)
+	}
+}
+
+func TestCRUD_controlParams(t *testing.T) {
+	c := testCrud(t, "create table items (id int64, format string, max int64);")
+	tb := c.schema.tables["items"]
+	r := httptest.NewRequest("GET", "/items?format=csv&max=2&_max=max&_format=ndjson", nil)
+	q, err := c.listQueryFrom("items", r, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.filters) != 2 {
+		t.Errorf("expected format and max to filter got %v", q.filters)
+	}
+	if f := negotiateFormat(tb, r); f != formatNDJSON {
+		t.Errorf("expected %s got %s", formatNDJSON, f)
+	}
+	if a := aggregateQueryFrom(tb, r); len(a.funcs["max"]) != 1 || a.funcs["max"][0] != "max" {
+		t.Errorf("expected the max of max got %v", a.funcs)
+	}
+	if f := negotiateFormat(tb, httptest.NewRequest("GET", "/items?format=csv", nil)); f != formatJSON {
+		t.Errorf("expected the format column to win got %s", f)
+	}
+	_, err = c.listQueryFrom("items", httptest.NewRequest("GET", "/items?_limit=1", nil), false)
+	if err != errPaging {
+		t.Errorf("expected %v got %v", errPaging, err)
+	}
+}
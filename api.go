@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/json"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"crypto/md5"
 
@@ -18,19 +22,26 @@ import (
 	"github.com/cznic/lldb"
 	"github.com/cznic/ql"
 	"github.com/gernest/alien"
+	"github.com/gernest/qlfu/graphql"
 )
 
 type api struct {
-	r       *alien.Mux
-	c       *crud
-	db      *ql.DB
-	dba     dba
-	service *service
-	baseURL string
+	r           *alien.Mux
+	c           *crud
+	db          *ql.DB
+	dba         dba
+	service     *service
+	baseURL     string
+	queryConfig queryConfig
+	gql         *graphql.Schema
+	changes     *changeFeed
+	hooks       *crudHooks
+	webhooks    webhookDispatcher
+	queries     chan struct{}
 }
 
 func newAPI(dir, baseURL string) (*api, error) {
-	a := &api{}
+	a := &api{changes: newChangeFeed(), hooks: newCrudHooks(), queries: make(chan struct{}, 1)}
 	s := newSdba(dir)
 	db, err := s.current()
 	if err != nil {
@@ -41,6 +52,10 @@ func newAPI(dir, baseURL string) (*api, error) {
 	a.db = db
 	a.dba = s
 	a.baseURL = baseURL
+	a.queryConfig = queryConfig{
+		timeout: defaultQueryTimeout,
+		limit:   defaultQueryLimit,
+	}
 	err = a.load()
 	if err != nil {
 		return nil, err
@@ -52,6 +67,24 @@ func (a *api) load() error {
 	r := alien.New()
 	_ = r.Get("/schema", a.schema)
 	_ = r.Post("/schema", a.newSchema)
+	_ = r.Post("/query", a.query)
+	_ = r.Get("/", a.explorer)
+	_ = r.Get("/ui", a.explorer)
+	_ = r.Get("/admin", a.admin)
+	_ = r.Get("/graphql", a.graphql)
+	_ = r.Post("/graphql", a.graphql)
+	_ = r.Get("/_webhooks", a.listWebhooks)
+	_ = r.Post("/_webhooks", a.createWebhook)
+	_ = r.Delete("/_webhooks/:id", a.deleteWebhook)
+	_ = r.Get("/_webhooks/:id/deliveries", a.webhookDeliveries)
+	gql, err := graphqlSchema(a.c)
+	if err != nil {
+		return err
+	}
+	a.gql = gql
+	a.c.changes = a.changes
+	a.c.hooks = a.hooks
+	a.syncWebhooks()
 	a.r = r
 	return a.handleService()
 }
@@ -70,17 +103,62 @@ func (a *api) schema(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *api) newSchema(w http.ResponseWriter, r *http.Request) {
-	s, err := schemaFromJSON(r.Body)
+	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		jsonErr(w, err, http.StatusBadRequest)
 		return
 	}
+	q := r.URL.Query()
+	format := schemaFormat(r)
+	if format == formatJSON && isJSONSchema(b) {
+		format = formatJSONSchema
+	}
+	var s *dbSchema
+	var ddl ql.List
+	switch format {
+	case formatQL:
+		ddl, err = compileDDL(string(b))
+	case formatJSONSchema:
+		s, err = schemaFromJSONSchema(bytes.NewReader(b))
+	case formatCSV:
+		sample, _ := strconv.Atoi(q.Get("sample"))
+		s, err = schemaFromCSV(q.Get("model"), bytes.NewReader(b), sample)
+	default:
+		s, err = schemaFromJSON(bytes.NewReader(b))
+	}
+	if err != nil {
+		jsonErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if v := r.URL.Query().Get("soft_delete"); v != "" {
+		if s == nil {
+			jsonErr(w, fmt.Errorf("soft_delete is not supported for %s schemas, add a %s time column instead", format, deletedAt), http.StatusBadRequest)
+			return
+		}
+		err = s.softDelete(strings.Split(v, ","))
+		if err != nil {
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+	if queryBool(r, "dry_run") {
+		if s != nil {
+			textOk(w, s.migration(0))
+		} else {
+			textOk(w, ddl.String())
+		}
+		return
+	}
 	db, err := a.dba.fresh()
 	if err != nil {
 		jsonErr(w, err, http.StatusBadRequest)
 		return
 	}
-	err = runMigration(db, s)
+	if s != nil {
+		err = runMigration(db, s)
+	} else {
+		err = runDDL(db, ddl)
+	}
 	if err != nil {
 		jsonErr(w, err, http.StatusInternalServerError)
 		return
@@ -91,19 +169,50 @@ func (a *api) newSchema(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	a.c = c
+	a.db = db
 	err = a.load()
 	if err != nil {
 		jsonErr(w, err, http.StatusInternalServerError)
 		return
 	}
+	if format == formatCSV && queryBool(r, "import") {
+		o, err := c.importRows(tableName(q.Get("model")), bytes.NewReader(b), importOptions{
+			format: formatCSV,
+		})
+		if err != nil {
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+		jsonRes(w, o)
+		return
+	}
 	jsonOk(w)
 }
 
+func schemaFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+	t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch t {
+	case "text/csv":
+		return formatCSV
+	case "application/schema+json":
+		return formatJSONSchema
+	case "text/plain":
+		return formatQL
+	}
+	return formatJSON
+}
+
 func jsonErr(w http.ResponseWriter, err error, code int) {
 	w.WriteHeader(code)
 	d := make(map[string]interface{})
 	d["error"] = err.Error()
 	d["message"] = http.StatusText(code)
+	if v, ok := err.(*validationError); ok {
+		d["fields"] = v.fields
+	}
 	b, _ := json.Marshal(d)
 	_, _ = w.Write(b)
 	w.Header().Set("Content-Type", "application/json")
@@ -165,6 +274,9 @@ func (s *sdba) fresh() (*ql.DB, error) {
 		CanCreate: true,
 		TempFile:  s.tmpFile,
 	})
+	if err != nil {
+		return nil, err
+	}
 	s.c = q
 	return s.c, nil
 }
@@ -176,8 +288,12 @@ func (s *sdba) tmpFile(dir, prefix string) (lldb.OSFile, error) {
 		return nil, err
 	}
 	m := fmt.Sprintf("%x", md5.Sum(b))
-	f := filepath.Join(s.dir, prefix, m)
-	return os.Open(f)
+	d := filepath.Join(s.dir, prefix)
+	err = os.MkdirAll(d, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(filepath.Join(d, m), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
 }
 
 func (s *sdba) close() error {
@@ -185,52 +301,25 @@ func (s *sdba) close() error {
 }
 
 type endpoint struct {
-	Path    string  `json:"path"`
-	Params  []param `json:"params"`
-	Method  string  `json:"method"`
-	Payload string  `json:"payload"`
-	handler http.HandlerFunc
-}
-
-const curlyGet = `
-curly %s
-`
-const curlyPost = `
-curly  -i -X POST \
-	%s \
-	-H "Content-Type:application/json" \
-	-d %s
-`
-
-func (e endpoint) curly(base string) string {
-	p := e.Path
-	switch e.Method {
-	case methodGet:
-		if e.Params != nil {
-			p = replaceParams(p, e.Params)
-		}
-		return fmt.Sprintf(curlyGet, base+p)
-	case methodPost:
-		if e.Params != nil {
-			p = replaceParams(p, e.Params)
-		}
-		return fmt.Sprintf(curlyPost, base+p, e.Payload)
-	}
-	return ""
+	Path     string  `json:"path"`
+	Params   []param `json:"params"`
+	Method   string  `json:"method"`
+	Payload  string  `json:"payload"`
+	handler  http.HandlerFunc
+	request  *jsonSchema
+	response *jsonSchema
 }
 
-func replaceParams(p string, params []param) string {
-	for _, v := range params {
-		p = strings.Replace(p, ":"+v.Name, fmt.Sprint(v.Default), -1)
-	}
-	return p
+type param struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Desc     string      `json:"desc"`
+	Default  interface{} `json:"default,omitempty"`
+	Required bool        `json:"required,omitempty"`
 }
 
-type param struct {
-	Name    string      `json:"name"`
-	Type    string      `json:"type"`
-	Desc    string      `json:"desc"`
-	Default interface{} `json:"default,omitempty"`
+func pathParam(e endpoint, p param) bool {
+	return strings.Contains(e.Path+"/", "/:"+p.Name+"/")
 }
 
 type service struct {
@@ -248,22 +337,38 @@ func (a *api) handleService() error {
 }
 
 func (a *api) registerService() error {
-	curl := a.r.Group(fmt.Sprintf("/curly/v%s", a.service.Version))
+	snip := a.r.Group(fmt.Sprintf("/snippets/v%s", a.service.Version))
 	e := a.r.Group(fmt.Sprintf("/v%s", a.service.Version))
 	for _, point := range a.service.Endpoints {
 		switch point.Method {
 		case methodGet:
-			_ = curl.Get(point.Path, curlHandler(a.baseURL, point))
+			_ = snip.Get(point.Path, a.snippetHandler(point))
 			_ = e.Get(point.Path, point.handler)
 		case methodPost:
-			_ = curl.Post(point.Path, curlHandler(a.baseURL, point))
+			_ = snip.Post(point.Path, a.snippetHandler(point))
 			_ = e.Post(point.Path, point.handler)
 		case methodPut:
-			_ = curl.Put(point.Path, curlHandler(a.baseURL, point))
+			_ = snip.Put(point.Path, a.snippetHandler(point))
 			_ = e.Put(point.Path, point.handler)
+		case methodPatch:
+			_ = snip.Patch(point.Path, a.snippetHandler(point))
+			_ = e.Patch(point.Path, point.handler)
+		case methodDelete:
+			_ = snip.Delete(point.Path, a.snippetHandler(point))
+			_ = e.Delete(point.Path, point.handler)
 		}
 	}
 	_ = a.r.Get(fmt.Sprintf("/v%s", a.service.Version), a.showService)
+	_ = e.Get("/openapi.json", a.openAPIJSON)
+	_ = e.Get("/openapi.yaml", a.openAPIYAML)
+	_ = e.Get("/_client/go", a.goClient)
+	_ = e.Get("/_client/ts", a.tsClient)
+	_ = e.Get("/_export/postman", a.exportPostman)
+	_ = e.Get("/_export/insomnia", a.exportInsomnia)
+	_ = e.Get("/_changes", a.changesSocket)
+	for _, m := range a.c.models() {
+		_ = e.Get("/"+m.name+"/_changes", a.c.changesHandler(m.name))
+	}
 	return nil
 }
 
@@ -271,13 +376,6 @@ func (a *api) showService(w http.ResponseWriter, r *http.Request) {
 	jsonRes(w, a.service)
 }
 
-func curlHandler(base string, e endpoint) func(http.ResponseWriter, *http.Request) {
-	txt := e.curly(base)
-	return func(w http.ResponseWriter, r *http.Request) {
-		textOk(w, txt)
-	}
-}
-
 func textOk(w http.ResponseWriter, txt string) {
 	w.Header().Set("Content-Type", "text/plain")
 	_, _ = w.Write([]byte(txt))
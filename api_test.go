@@ -1,34 +1,48 @@
 package main
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/cznic/ql"
 )
 
-func TestCurly(t *testing.T) {
-	payload1 := `
-curly  -i -X POST \
-	http://localhost:8090/api/v1/products \
-	-H "Content-Type:application/json" \
-	-d {"name":"ugali"}
-	`
-	base := "http://localhost:8090/api/v1"
+func TestSnippets(t *testing.T) {
+	a := &api{baseURL: "http://localhost:8090"}
+	render := func(e endpoint, lang string) (string, int) {
+		p := e.Path
+		if e.Params != nil {
+			p = replaceParams(p, e.Params)
+		}
+		w := httptest.NewRecorder()
+		a.snippetHandler(e)(w, httptest.NewRequest(strings.ToUpper(e.Method), "/snippets/v1"+p+"?lang="+lang, nil))
+		return w.Body.String(), w.Code
+	}
+	post := endpoint{
+		Path:    "/products",
+		Method:  methodPost,
+		Payload: `{"name":"ugali's"}`,
+	}
 	sample := []struct {
-		e     endpoint
-		curly string
+		e       endpoint
+		lang    string
+		snippet string
 	}{
 		{
 			endpoint{
 				Path:   "/products",
 				Method: methodGet,
-			}, "curly " + base + "/products",
+			}, "curl", "curl -i -X GET 'http://localhost:8090/v1/products'",
 		},
 		{
-			endpoint{
-				Path:    "/products",
-				Method:  methodPost,
-				Payload: `{"name":"ugali"}`,
-			}, payload1,
+			post, "curl", `curl -i -X POST 'http://localhost:8090/v1/products' \
+  -H 'Content-Type: application/json' \
+  -d '{"name":"ugali'\''s"}'`,
 		},
 		{
 			endpoint{
@@ -36,19 +50,131 @@ curly  -i -X POST \
 				Params: []param{
 					{
 						Name:    "id",
-						Type:    "ind64",
+						Type:    "int64",
 						Default: 1,
 					},
 				},
-				Method: methodGet,
-			}, "curly " + base + "/products/1",
+				Method: methodDelete,
+			}, "curl", "curl -i -X DELETE 'http://localhost:8090/v1/products/1'",
+		},
+		{
+			post, "httpie", `printf '%s' '{"name":"ugali'\''s"}' | http POST 'http://localhost:8090/v1/products' Content-Type:application/json`,
+		},
+		{
+			post, "wget", `wget -q -O - --method=POST \
+  --header='Content-Type: application/json' \
+  --body-data='{"name":"ugali'\''s"}' \
+  'http://localhost:8090/v1/products'`,
+		},
+		{
+			post, "python", `import requests
+
+res = requests.post(
+    "http://localhost:8090/v1/products",
+    data="{\"name\":\"ugali's\"}",
+    headers={"Content-Type": "application/json"},
+)
+print(res.status_code, res.text)`,
+		},
+		{
+			post, "fetch", `fetch("http://localhost:8090/v1/products", {
+  method: "POST",
+  headers: {"Content-Type": "application/json"},
+  body: JSON.stringify({
+    "name": "ugali's"
+  })
+})
+  .then(res => res.text())
+  .then(console.log);`,
 		},
 	}
 	for _, v := range sample {
-		e := strings.TrimSpace(v.e.curly(base))
-		c := strings.TrimSpace(v.curly)
-		if e != c {
-			t.Errorf("expected %s got %s", c, e)
+		s, code := render(v.e, v.lang)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected %d got %d %s", v.lang, http.StatusOK, code, s)
+		}
+		s = strings.TrimSpace(s)
+		if s != v.snippet {
+			t.Errorf("%s: expected %s got %s", v.lang, v.snippet, s)
+		}
+	}
+	s, _ := render(post, "go")
+	for _, v := range []string{
+		`http.NewRequest("POST", "http://localhost:8090/v1/products", strings.NewReader("{\"name\":\"ugali's\"}"))`,
+		`req.Header.Set("Content-Type", "application/json")`,
+	} {
+		if !strings.Contains(s, v) {
+			t.Errorf("expected %s in %s", v, s)
 		}
 	}
+	if _, code := render(post, "cobol"); code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, code)
+	}
+}
+
+func testAPI(t *testing.T) (*api, func()) {
+	dir, err := ioutil.TempDir("", "qlfu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := newAPI(dir, "http://localhost:8090")
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return a, func() {
+		_ = a.dba.close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestAPI_snippets(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"user":{"username":"gernest"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("PUT", "/snippets/v1/users/5?lang=httpie&strict=true", strings.NewReader(`{"username":"geofrey"}`)))
+	expect := `printf '%s' '{"username":"geofrey"}' | http PUT 'http://localhost:8090/v1/users/5?strict=true' Content-Type:application/json`
+	if s := strings.TrimSpace(w.Body.String()); s != expect {
+		t.Errorf("expected %s got %s", expect, s)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/snippets/v1/users?lang=cobol", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestSdba_tmpFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qlfu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSdba(dir)
+	defer func() {
+		_ = s.close()
+	}()
+	f, err := s.tmpFile("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt([]byte("ok"), 0); err != nil {
+		t.Errorf("expected a writable temp file got %v", err)
+	}
+	_ = f.Close()
+	db, err := s.fresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = db.Run(ql.NewRWCtx(), "begin transaction; create table t (a int); insert into t values (1); commit;"); err != nil {
+		t.Errorf("expected writes to a file database to work got %v", err)
+	}
+	s.dir = filepath.Join(dir, "missing", "\x00")
+	if _, err = s.fresh(); err == nil {
+		t.Error("expected an error opening a database in a bad path")
+	}
 }
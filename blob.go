@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cznic/ql"
+)
+
+const maxUploadMemory = 32 << 20
+
+func parseDataURI(src string) ([]byte, string, bool) {
+	if !strings.HasPrefix(src, "data:") {
+		return nil, "", false
+	}
+	i := strings.IndexByte(src, ',')
+	if i < 0 {
+		return nil, "", false
+	}
+	meta, data := src[len("data:"):i], src[i+1:]
+	b64 := strings.HasSuffix(meta, ";base64")
+	if b64 {
+		meta = strings.TrimSuffix(meta, ";base64")
+	}
+	if meta == "" {
+		meta = "text/plain;charset=US-ASCII"
+	}
+	if b64 {
+		b, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, "", false
+		}
+		return b, meta, true
+	}
+	v, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, "", false
+	}
+	return []byte(v), meta, true
+}
+
+func decodeBlob(src string) ([]byte, error) {
+	if b, _, ok := parseDataURI(src); ok {
+		return b, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(src)
+	if err != nil {
+		return nil, errors.New("expected base64 encoded blob or data uri")
+	}
+	return b, nil
+}
+
+func isMultipart(r *http.Request) bool {
+	t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return t == "multipart/form-data"
+}
+
+func multipartProps(r *http.Request) (modelProps, error) {
+	err := r.ParseMultipartForm(maxUploadMemory)
+	if err != nil {
+		return nil, err
+	}
+	prop := make(modelProps)
+	for k, v := range r.MultipartForm.Value {
+		if len(v) > 0 {
+			setProp(prop, k, v[0])
+		}
+	}
+	for k, v := range r.MultipartForm.File {
+		if len(v) == 0 {
+			continue
+		}
+		f, err := v[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		setProp(prop, k, b)
+	}
+	return prop, nil
+}
+
+// setProp supports profile.country style names for nested relation objects.
+func setProp(p modelProps, name string, v interface{}) {
+	i := strings.IndexByte(name, '.')
+	if i < 0 {
+		p[name] = v
+		return
+	}
+	n, ok := p.propProperty(name[:i])
+	if !ok {
+		n = make(modelProps)
+		p[name[:i]] = map[string]interface{}(n)
+	}
+	setProp(n, name[i+1:], v)
+}
+
+func (c *crud) blobHandler(model, col string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		i, err := idParam(r)
+		if err != nil {
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+		o, err := c.getByID(model, i, scopeLive)
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		if o == nil {
+			jsonErr(w, errNotFound, http.StatusNotFound)
+			return
+		}
+		b, ok := o[0][col].([]byte)
+		if !ok {
+			jsonErr(w, errors.New("no data found"), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", http.DetectContentType(b))
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		_, _ = w.Write(b)
+	}
+}
+
+func blobColumns(t *table) []*column {
+	var o []*column
+	for _, c := range t.columns {
+		if c.typ == ql.Blob {
+			o = append(o, c)
+		}
+	}
+	return o
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cznic/ql"
+	"github.com/gernest/alien"
+)
+
+func TestParseDataURI(t *testing.T) {
+	sample := []struct {
+		src, data, typ string
+	}{
+		{"data:image/png;base64,aGVsbG8=", "hello", "image/png"},
+		{"data:,hello%20world", "hello world", "text/plain;charset=US-ASCII"},
+	}
+	for _, v := range sample {
+		b, typ, ok := parseDataURI(v.src)
+		if !ok {
+			t.Errorf("expected %s to be a data uri", v.src)
+			continue
+		}
+		if string(b) != v.data {
+			t.Errorf("expected %s got %s", v.data, b)
+		}
+		if typ != v.typ {
+			t.Errorf("expected %s got %s", v.typ, typ)
+		}
+	}
+	if _, _, ok := parseDataURI("hello"); ok {
+		t.Error("expected hello not to be a data uri")
+	}
+	if stringType("data:image/png;base64,aGVsbG8=") != ql.Blob {
+		t.Error("expected data uri to be a blob")
+	}
+}
+
+func TestCRUD_blob(t *testing.T) {
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	_, _, err = db.Run(ql.NewRWCtx(), `
+	begin transaction;
+		create table files(
+			id int64,
+			name string,
+			data blob,
+		);
+	commit;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := alien.New()
+	_ = r.Post("/files", c.createHandler("files"))
+	_ = r.Get("/files/:id/data", c.blobHandler("files", "data"))
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("name", "hello.txt")
+	fw, err := mw.CreateFormFile("data", "hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write([]byte("hello world"))
+	_ = mw.Close()
+	req := httptest.NewRequest("POST", "/files", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	req = httptest.NewRequest("POST", "/files", bytes.NewBufferString(`{"name":"b.txt","data":"data:text/plain;base64,Ynll"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	o := make(modelProps)
+	_ = json.Unmarshal(w.Body.Bytes(), &o)
+	if o["data"] != "Ynll" {
+		t.Errorf("expected base64 encoded data got %v", o["data"])
+	}
+
+	req = httptest.NewRequest("GET", "/files/1/data", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	if w.Body.String() != "hello world" {
+		t.Errorf("expected hello world got %s", w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("expected text/plain got %s", ct)
+	}
+}
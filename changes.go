@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	changeCreate = "create"
+	changeUpdate = "update"
+	changeDelete = "delete"
+)
+
+const changeBuffer = 256
+
+var changeKeepAlive = 15 * time.Second
+
+type change struct {
+	Seq    int64      `json:"seq"`
+	Model  string     `json:"model"`
+	ID     int64      `json:"id"`
+	Op     string     `json:"op"`
+	Record modelProps `json:"record"`
+	Time   time.Time  `json:"time"`
+}
+
+// changeFeed drops subscribers that fall more than changeBuffer events behind,
+// the sink gets every event in order and must not block.
+type changeFeed struct {
+	mu   sync.Mutex
+	seq  int64
+	subs map[chan *change]bool
+	sink func(*change)
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{subs: make(map[chan *change]bool)}
+}
+
+func (f *changeFeed) subscribe() chan *change {
+	ch := make(chan *change, changeBuffer)
+	f.mu.Lock()
+	f.subs[ch] = true
+	f.mu.Unlock()
+	return ch
+}
+
+func (f *changeFeed) unsubscribe(ch chan *change) {
+	f.mu.Lock()
+	if f.subs[ch] {
+		delete(f.subs, ch)
+		close(ch)
+	}
+	f.mu.Unlock()
+}
+
+func (f *changeFeed) follow(fn func(*change)) {
+	f.mu.Lock()
+	f.sink = fn
+	f.mu.Unlock()
+}
+
+func (f *changeFeed) publish(model string, id int64, op string, record modelProps) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	e := &change{Seq: f.seq, Model: model, ID: id, Op: op, Record: record, Time: time.Now()}
+	if f.sink != nil {
+		f.sink(e)
+	}
+	for ch := range f.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (f *changeFeed) active() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs) > 0 || f.sink != nil
+}
+
+func matchChange(e *change, model string, filters []*field) bool {
+	if e.Model != model {
+		return false
+	}
+	for _, f := range filters {
+		if !sameValue(e.Record[f.Name], f.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameValue(a, b interface{}) bool {
+	if t, ok := a.(time.Time); ok {
+		if v, ok := b.(time.Time); ok {
+			return t.Equal(v)
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func (c *crud) changesHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := c.listQueryFrom(model, r, false)
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			jsonErr(w, fmt.Errorf("streaming is not supported"), http.StatusInternalServerError)
+			return
+		}
+		events := c.changes.subscribe()
+		defer c.changes.unsubscribe(events)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+		tick := time.NewTicker(changeKeepAlive)
+		defer tick.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-tick.C:
+				_, _ = fmt.Fprint(w, ": ping\n\n")
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if !matchChange(e, model, q.filters) {
+					continue
+				}
+				b, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Op, b)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+type changeMessage struct {
+	Type   string                 `json:"type"`
+	ID     string                 `json:"id"`
+	Model  string                 `json:"model,omitempty"`
+	Filter map[string]interface{} `json:"filter,omitempty"`
+}
+
+type changeReply struct {
+	Type   string            `json:"type"`
+	ID     string            `json:"id,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Change *change           `json:"change,omitempty"`
+}
+
+type changeSubscription struct {
+	model   string
+	filters []*field
+}
+
+func (c *crud) changeFilters(model string, filter map[string]interface{}) ([]*field, error) {
+	t, ok := c.schema.tables[model]
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", model)
+	}
+	var o []*field
+	verr := &validationError{}
+	for k, v := range filter {
+		idx, ok := t.colID(k)
+		if !ok {
+			verr.add(k, "unknown column")
+			continue
+		}
+		cv, err := coerce(t.columns[idx].typ, v)
+		if err != nil {
+			verr.add(k, err.Error())
+			continue
+		}
+		o = append(o, &field{Name: k, value: cv})
+	}
+	if verr.failed() {
+		return nil, verr
+	}
+	return o, nil
+}
+
+func (a *api) changesSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrade(w, r)
+	if err != nil {
+		jsonErr(w, err, http.StatusBadRequest)
+		return
+	}
+	defer conn.close()
+	events := a.changes.subscribe()
+	var mu sync.Mutex
+	subs := make(map[string]*changeSubscription)
+	send := func(v *changeReply) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return conn.writeText(b)
+	}
+	go func() {
+		defer a.changes.unsubscribe(events)
+		for {
+			b, err := conn.readText()
+			if err != nil {
+				return
+			}
+			var m changeMessage
+			if err := decodeJSON(b, &m); err != nil {
+				_ = send(&changeReply{Type: "error", Error: err.Error()})
+				continue
+			}
+			switch m.Type {
+			case "subscribe":
+				filters, err := a.c.changeFilters(m.Model, m.Filter)
+				if err != nil {
+					reply := &changeReply{Type: "error", ID: m.ID, Error: err.Error()}
+					if v, ok := err.(*validationError); ok {
+						reply.Fields = v.fields
+					}
+					_ = send(reply)
+					continue
+				}
+				mu.Lock()
+				subs[m.ID] = &changeSubscription{model: m.Model, filters: filters}
+				mu.Unlock()
+				_ = send(&changeReply{Type: "subscribed", ID: m.ID})
+			case "unsubscribe":
+				mu.Lock()
+				delete(subs, m.ID)
+				mu.Unlock()
+				_ = send(&changeReply{Type: "unsubscribed", ID: m.ID})
+			default:
+				_ = send(&changeReply{Type: "error", ID: m.ID, Error: fmt.Sprintf("unknown message type %q", m.Type)})
+			}
+		}
+	}()
+	for e := range events {
+		mu.Lock()
+		var ids []string
+		for id, s := range subs {
+			if matchChange(e, s.model, s.filters) {
+				ids = append(ids, id)
+			}
+		}
+		mu.Unlock()
+		for _, id := range ids {
+			if err := send(&changeReply{Type: "change", ID: id, Change: e}); err != nil {
+				a.changes.unsubscribe(events)
+			}
+		}
+	}
+}
+
+func decodeJSON(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testChangesAPI(t *testing.T) (*api, *httptest.Server, func()) {
+	a, done := testAPI(t)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"user":{"username":"gernest","email":"gernest@example.com"}}`)))
+	if w.Code != http.StatusOK {
+		done()
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	ts := httptest.NewServer(a)
+	return a, ts, func() {
+		ts.Close()
+		done()
+	}
+}
+
+func testWrite(t *testing.T, ts *httptest.Server, method, path, body string) []byte {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("%s %s: expected %d got %d %s", method, path, http.StatusOK, res.StatusCode, b)
+	}
+	return b
+}
+
+func TestAPI_changesSSE(t *testing.T) {
+	_, ts, done := testChangesAPI(t)
+	defer done()
+	res, err := http.Get(ts.URL + "/v1/users/_changes?username=john")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream got %s", ct)
+	}
+	events := make(chan []string)
+	go func() {
+		br := bufio.NewReader(res.Body)
+		var e []string
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				close(events)
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line != "" {
+				e = append(e, line)
+				continue
+			}
+			events <- e
+			e = nil
+		}
+	}()
+	next := func() []string {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+		return nil
+	}
+	if e := next(); len(e) != 1 || e[0] != ": connected" {
+		t.Fatalf("expected a connected comment got %v", e)
+	}
+
+	testWrite(t, ts, "POST", "/v1/users", `{"username":"gernest"}`)
+	testWrite(t, ts, "POST", "/v1/users", `{"username":"john","email":"john@example.com"}`)
+	testWrite(t, ts, "PUT", "/v1/users/2", `{"email":"j@example.com"}`)
+	testWrite(t, ts, "DELETE", "/v1/users/2", "")
+	testWrite(t, ts, "POST", "/v1/users/_import", `{"username":"john","email":"john@example.com"}`)
+
+	for _, v := range []struct {
+		id        int64
+		op, email string
+	}{
+		{2, changeCreate, "john@example.com"},
+		{2, changeUpdate, "j@example.com"},
+		{2, changeDelete, "j@example.com"},
+		{3, changeCreate, "john@example.com"},
+	} {
+		e := next()
+		if len(e) != 3 || e[1] != "event: "+v.op || !strings.HasPrefix(e[2], "data: ") {
+			t.Fatalf("expected a %s event got %v", v.op, e)
+		}
+		var c change
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(e[2], "data: ")), &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Model != "users" || c.ID != v.id || c.Op != v.op || c.Record["email"] != v.email {
+			t.Errorf("unexpected change %+v", c)
+		}
+	}
+
+	res, err = http.Get(ts.URL + "/v1/users/_changes?id=nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d got %d", http.StatusUnprocessableEntity, res.StatusCode)
+	}
+}
+
+type testWSConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWS(t *testing.T, ts *httptest.Server, path string) *testWSConn {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	_, err = io.WriteString(conn, "GET "+path+" HTTP/1.1\r\n"+
+		"Host: example.com\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: "+key+"\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected %d got %d", http.StatusSwitchingProtocols, res.StatusCode)
+	}
+	if v := res.Header.Get("Sec-WebSocket-Accept"); v != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %s", v)
+	}
+	return &testWSConn{conn: conn, br: br}
+}
+
+func (c *testWSConn) send(t *testing.T, msg string) {
+	mask := []byte{1, 2, 3, 4}
+	b := []byte{0x80 | wsText, 0x80 | byte(len(msg))}
+	b = append(b, mask...)
+	for i := 0; i < len(msg); i++ {
+		b = append(b, msg[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testWSConn) read(t *testing.T) *changeReply {
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(hdr[1] & 0x7F)
+	if n == 126 {
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			t.Fatal(err)
+		}
+		n = int(binary.BigEndian.Uint16(b[:]))
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.br, b); err != nil {
+		t.Fatal(err)
+	}
+	var o changeReply
+	if err := json.Unmarshal(b, &o); err != nil {
+		t.Fatalf("%v: %s", err, b)
+	}
+	return &o
+}
+
+func TestAPI_changesSocket(t *testing.T) {
+	_, ts, done := testChangesAPI(t)
+	defer done()
+	ws := dialWS(t, ts, "/v1/_changes")
+	defer ws.conn.Close()
+
+	ws.send(t, `{"type":"subscribe","id":"bad","model":"users","filter":{"id":"nope"}}`)
+	if r := ws.read(t); r.Type != "error" || r.ID != "bad" || r.Fields["id"] == "" {
+		t.Fatalf("expected a validation error got %+v", r)
+	}
+	ws.send(t, `{"type":"subscribe","id":"john","model":"users","filter":{"username":"john"}}`)
+	if r := ws.read(t); r.Type != "subscribed" || r.ID != "john" {
+		t.Fatalf("expected subscribed got %+v", r)
+	}
+	ws.send(t, `{"type":"subscribe","id":"all","model":"users"}`)
+	if r := ws.read(t); r.Type != "subscribed" || r.ID != "all" {
+		t.Fatalf("expected subscribed got %+v", r)
+	}
+
+	testWrite(t, ts, "POST", "/v1/users", `{"username":"gernest"}`)
+	r := ws.read(t)
+	if r.Type != "change" || r.ID != "all" || r.Change.Op != changeCreate || r.Change.Record["username"] != "gernest" {
+		t.Fatalf("expected a create change for all got %+v", r)
+	}
+
+	ws.send(t, `{"type":"unsubscribe","id":"all"}`)
+	if r := ws.read(t); r.Type != "unsubscribed" || r.ID != "all" {
+		t.Fatalf("expected unsubscribed got %+v", r)
+	}
+	testWrite(t, ts, "POST", "/v1/users", `{"username":"john"}`)
+	r = ws.read(t)
+	if r.Type != "change" || r.ID != "john" || r.Change.ID != 2 || r.Change.Record["username"] != "john" {
+		t.Fatalf("expected a create change for john got %+v", r)
+	}
+}
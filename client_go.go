@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"net/http"
+	"text/template"
+
+	"github.com/cznic/ql"
+	"github.com/jinzhu/inflection"
+)
+
+var goClientTpl = template.Must(template.New("go-client").Parse(goClientSrc))
+
+type goClientData struct {
+	Package string
+	Version string
+	Time    bool
+	Big     bool
+	Models  []*goModel
+}
+
+type goModel struct {
+	Name       string
+	Plural     string
+	Table      string
+	Fields     []*goField
+	Relations  []*goField
+	Blobs      []*goField
+	SoftDelete bool
+}
+
+// goField is a field of a generated struct. Omit is false for the not null
+// values, so an update sends their zero values too.
+type goField struct {
+	Name   string
+	Type   string
+	JSON   string
+	Omit   bool
+	Column string
+	QL     string
+}
+
+// goReserved are the names the client itself declares, models with one of them
+// get a Record suffix.
+var goReserved = map[string]bool{
+	"Client":   true,
+	"Error":    true,
+	"New":      true,
+	"NotFound": true,
+}
+
+func goModelName(table string) string {
+	n := modelTypeName(table)
+	if goReserved[n] {
+		return n + "Record"
+	}
+	return n
+}
+
+func goModels(c *crud) []*goModel {
+	var o []*goModel
+	for _, t := range c.models() {
+		m := &goModel{
+			Name:       goModelName(t.name),
+			Plural:     goName(t.name),
+			Table:      t.name,
+			SoftDelete: t.softDelete,
+		}
+		for _, col := range t.columns {
+			typ := goType(col.typ)
+			if !col.notNull && col.name != "id" && typ[0] != '[' && typ[0] != '*' {
+				typ = "*" + typ
+			}
+			m.Fields = append(m.Fields, &goField{
+				Name:   goName(col.name),
+				Type:   typ,
+				JSON:   col.name,
+				Omit:   col.name == "id" || typ[0] == '*' || typ[0] == '[' || typ == "interface{}",
+				Column: col.name,
+			})
+		}
+		if t.hasOne != nil {
+			r := &goField{
+				Name: modelTypeName(t.hasOne.destTable),
+				Type: "*" + goModelName(t.hasOne.destTable),
+				JSON: inflection.Singular(t.hasOne.destTable),
+				Omit: true,
+			}
+			if !goFieldTaken(m.Fields, r) {
+				m.Relations = append(m.Relations, r)
+			}
+		}
+		for _, col := range blobColumns(t) {
+			m.Blobs = append(m.Blobs, &goField{Name: goName(col.name), Column: col.name})
+		}
+		o = append(o, m)
+	}
+	return o
+}
+
+func goFieldTaken(fields []*goField, f *goField) bool {
+	for _, v := range fields {
+		if v.Name == f.Name || v.JSON == f.JSON {
+			return true
+		}
+	}
+	return false
+}
+
+func goType(typ ql.Type) string {
+	switch typ {
+	case ql.Bool:
+		return "bool"
+	case ql.Int8, ql.Int16, ql.Int32, ql.Int64,
+		ql.Uint8, ql.Uint16, ql.Uint32, ql.Uint64,
+		ql.Float32, ql.Float64, ql.String:
+		return typ.String()
+	case ql.Blob:
+		return "[]byte"
+	case ql.Time:
+		return "time.Time"
+	case ql.Duration:
+		return "time.Duration"
+	case ql.BigInt:
+		return "*big.Int"
+	case ql.BigRat:
+		return "*big.Rat"
+	}
+	return "interface{}"
+}
+
+func genGoClient(c *crud, pkg string) ([]byte, error) {
+	d := goClientData{
+		Package: pkg,
+		Version: activeVersion,
+		Models:  goModels(c),
+	}
+	for _, m := range d.Models {
+		for _, f := range m.Fields {
+			switch f.Type {
+			case "time.Time", "*time.Time", "time.Duration", "*time.Duration":
+				d.Time = true
+			case "*big.Int", "*big.Rat":
+				d.Big = true
+			}
+		}
+	}
+	var buf bytes.Buffer
+	err := goClientTpl.Execute(&buf, d)
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func (a *api) goClient(w http.ResponseWriter, r *http.Request) {
+	pkg := r.URL.Query().Get("package")
+	if pkg == "" {
+		pkg = "client"
+	}
+	b, err := genGoClient(a.c, pkg)
+	if err != nil {
+		jsonErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	textOk(w, string(b))
+}
+
+const goClientSrc = `// Code generated by qlfu. DO NOT EDIT.
+
+// Package {{.Package}} is a client for the qlfu generated api.
+package {{.Package}}
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+{{- if .Big}}
+	"math/big"
+{{- end}}
+	"net/http"
+	"net/url"
+	"strings"
+{{- if .Time}}
+	"time"
+{{- end}}
+)
+
+// Client talks to a qlfu server.
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// New returns a client for the qlfu server at baseURL, for example
+// http://localhost:8090.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		HTTP:    http.DefaultClient,
+	}
+}
+
+// Error is returned for every response other than 200 OK.
+type Error struct {
+	Status  int               ` + "`" + `json:"-"` + "`" + `
+	Message string            ` + "`" + `json:"error"` + "`" + `
+	Fields  map[string]string ` + "`" + `json:"fields,omitempty"` + "`" + `
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("qlfu: %d %s", e.Status, e.Message)
+}
+
+// NotFound reports whether err is a 404 from the server.
+func NotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.Status == http.StatusNotFound
+}
+
+func (c *Client) do(method, path string, query url.Values, in, out interface{}) error {
+	u := c.BaseURL + "/v{{.Version}}" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		e := &Error{Status: res.StatusCode}
+		if json.Unmarshal(b, e) != nil || e.Message == "" {
+			e.Message = strings.TrimSpace(string(b))
+		}
+		return e
+	}
+	switch v := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*v = b
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+{{range .Models}}{{$m := .}}
+// {{.Name}} is a record of the {{.Table}} table.
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`" + `json:"{{.JSON}}{{if .Omit}},omitempty{{end}}"` + "`" + `
+{{- end}}
+{{- range .Relations}}
+	{{.Name}} {{.Type}} ` + "`" + `json:"{{.JSON}},omitempty"` + "`" + `
+{{- end}}
+}
+
+// Create{{.Name}} inserts v and returns the stored record.
+func (c *Client) Create{{.Name}}(v *{{.Name}}) (*{{.Name}}, error) {
+	o := &{{.Name}}{}
+	err := c.do("POST", "/{{.Table}}", nil, v, o)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Get{{.Name}} returns the {{.Table}} record with the given id.
+func (c *Client) Get{{.Name}}(id int64) (*{{.Name}}, error) {
+	var o []*{{.Name}}
+	err := c.do("GET", fmt.Sprintf("/{{.Table}}/%d", id), nil, nil, &o)
+	if err != nil {
+		return nil, err
+	}
+	if len(o) == 0 {
+		return nil, &Error{Status: http.StatusNotFound, Message: "no records found"}
+	}
+	return o[0], nil
+}
+
+// List{{.Plural}} returns {{.Table}} records, query takes column filters and
+// limit and offset.
+func (c *Client) List{{.Plural}}(query url.Values) ([]*{{.Name}}, error) {
+	var o []*{{.Name}}
+	err := c.do("GET", "/{{.Table}}", query, nil, &o)
+	if NotFound(err) {
+		return nil, nil
+	}
+	return o, err
+}
+
+// Count{{.Plural}} returns the number of {{.Table}} records matching the column
+// filters in query.
+func (c *Client) Count{{.Plural}}(query url.Values) (int64, error) {
+	var o struct {
+		Count int64 ` + "`" + `json:"count"` + "`" + `
+	}
+	err := c.do("GET", "/{{.Table}}/_count", query, nil, &o)
+	return o.Count, err
+}
+
+// Update{{.Name}} updates the {{.Table}} record with the given id, the not
+// null columns are always sent.
+func (c *Client) Update{{.Name}}(id int64, v *{{.Name}}) (*{{.Name}}, error) {
+	o := &{{.Name}}{}
+	err := c.do("PUT", fmt.Sprintf("/{{.Table}}/%d", id), nil, v, o)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Upsert{{.Name}} updates the {{.Table}} record matching v on the given columns
+// or inserts v, op is either inserted or updated.
+func (c *Client) Upsert{{.Name}}(on []string, v *{{.Name}}) (op string, o *{{.Name}}, err error) {
+	var res struct {
+		Op     string ` + "`" + `json:"op"` + "`" + `
+		Record *{{.Name}} ` + "`" + `json:"record"` + "`" + `
+	}
+	err = c.do("PUT", "/{{.Table}}", url.Values{"on": {strings.Join(on, ",")}}, v, &res)
+	return res.Op, res.Record, err
+}
+
+// Delete{{.Name}} deletes the {{.Table}} record with the given id.
+func (c *Client) Delete{{.Name}}(id int64) error {
+	return c.do("DELETE", fmt.Sprintf("/{{.Table}}/%d", id), nil, nil, nil)
+}
+{{- if .SoftDelete}}
+
+// ListDeleted{{.Plural}} returns the soft deleted {{.Table}} records.
+func (c *Client) ListDeleted{{.Plural}}() ([]*{{.Name}}, error) {
+	var o []*{{.Name}}
+	err := c.do("GET", "/{{.Table}}/_trash", nil, nil, &o)
+	if NotFound(err) {
+		return nil, nil
+	}
+	return o, err
+}
+
+// Restore{{.Name}} brings back the soft deleted {{.Table}} record with the given id.
+func (c *Client) Restore{{.Name}}(id int64) (*{{.Name}}, error) {
+	o := &{{.Name}}{}
+	err := c.do("POST", fmt.Sprintf("/{{.Table}}/%d/restore", id), nil, nil, o)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+{{- end}}
+{{- range .Blobs}}
+
+// Get{{$m.Name}}{{.Name}} returns the raw {{.Column}} of the {{$m.Table}} record with the given id.
+func (c *Client) Get{{$m.Name}}{{.Name}}(id int64) ([]byte, error) {
+	var o []byte
+	err := c.do("GET", fmt.Sprintf("/{{$m.Table}}/%d/{{.Column}}", id), nil, nil, &o)
+	return o, err
+}
+{{- end}}
+{{end}}`
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cznic/ql"
+)
+
+func TestGoName(t *testing.T) {
+	sample := []struct {
+		src, name string
+	}{
+		{"users", "Users"},
+		{"users_id", "UsersID"},
+		{"avatar_url", "AvatarURL"},
+		{"created-at", "CreatedAt"},
+		{"2fa", "X2fa"},
+	}
+	for _, v := range sample {
+		if n := goName(v.src); n != v.name {
+			t.Errorf("%s: expected %s got %s", v.src, v.name, n)
+		}
+	}
+}
+
+func testCrud(t *testing.T, src string) *crud {
+	l, err := compileDDL(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runDDL(db, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var srcImporter = importer.ForCompiler(token.NewFileSet(), "source", nil)
+
+// typeCheck fails t when the generated client src does not compile.
+func typeCheck(t *testing.T, src []byte) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "client.go", src, 0)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	conf := types.Config{Importer: srcImporter}
+	if _, err = conf.Check("client", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+}
+
+func TestGenGoClient(t *testing.T) {
+	c := testCrud(t, ddlSample+`
+create table posts (
+	title      string not null,
+	cover      blob,
+	created_at time,
+	deleted_at time,
+);
+`)
+	b, err := genGoClient(c, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeCheck(t, b)
+	s := strings.Join(strings.Fields(string(b)), " ")
+	for _, v := range []string{
+		"package client",
+		"type User struct",
+		"Username string `json:\"username\"`",
+		"Email *string `json:\"email,omitempty\"`",
+		"CreatedAt *time.Time `json:\"created_at,omitempty\"`",
+		"func (c *Client) CreateUser(v *User) (*User, error)",
+		"func (c *Client) GetUser(id int64) (*User, error)",
+		"func (c *Client) ListUsers(query url.Values) ([]*User, error)",
+		"func (c *Client) UpdateUser(id int64, v *User) (*User, error)",
+		"func (c *Client) DeleteUser(id int64) error",
+		"func (c *Client) ListDeletedPosts() ([]*Post, error)",
+		"func (c *Client) RestorePost(id int64) (*Post, error)",
+		"func (c *Client) GetPostCover(id int64) ([]byte, error)",
+	} {
+		if !strings.Contains(s, v) {
+			t.Errorf("expected %s in\n%s", v, s)
+		}
+	}
+	if strings.Contains(s, "func (c *Client) RestoreUser") {
+		t.Error("expected no restore for models without soft delete")
+	}
+}
+
+func TestGenGoClient_reserved(t *testing.T) {
+	c := testCrud(t, `
+create table clients (name string);
+create table errors (message string, clients_id int64);
+create table not_founds (title string);
+`)
+	b, err := genGoClient(c, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeCheck(t, b)
+	s := strings.Join(strings.Fields(string(b)), " ")
+	for _, v := range []string{
+		"type ClientRecord struct",
+		"type ErrorRecord struct",
+		"type NotFoundRecord struct",
+		"func (c *Client) CreateClientRecord(v *ClientRecord) (*ClientRecord, error)",
+		"func (c *Client) ListClients(query url.Values) ([]*ClientRecord, error)",
+	} {
+		if !strings.Contains(s, v) {
+			t.Errorf("expected %s in\n%s", v, s)
+		}
+	}
+}
+
+func TestGenGoClient_relationNames(t *testing.T) {
+	c := testCrud(t, `
+create table authors (id int64, name string);
+create table books (id int64, author string, authors_id int64);
+`)
+	b, err := genGoClient(c, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeCheck(t, b)
+	s := strings.Join(strings.Fields(string(b)), " ")
+	if !strings.Contains(s, "Author *string `json:\"author,omitempty\"`") || strings.Contains(s, "Author *Author `") {
+		t.Errorf("expected the author column to win over the relation in\n%s", s)
+	}
+}
+
+func TestAPI_goClient(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"user":{"username":"gernest","profile":{"bio":"gopher"}}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/_client/go?package=qlfu", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	s := strings.Join(strings.Fields(w.Body.String()), " ")
+	for _, v := range []string{
+		"package qlfu",
+		"type Profile struct",
+		"func (c *Client) CreateProfile(v *Profile) (*Profile, error)",
+	} {
+		if !strings.Contains(s, v) {
+			t.Errorf("expected %s in\n%s", v, s)
+		}
+	}
+	if !strings.Contains(s, "Profile *Profile `json:\"profile,omitempty\"`") {
+		t.Errorf("expected a profile relation field in\n%s", s)
+	}
+}
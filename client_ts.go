@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/cznic/ql"
+	"github.com/jinzhu/inflection"
+)
+
+var tsClientTpl = template.Must(template.New("ts-client").Parse(tsClientSrc))
+
+var tsIdent = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+type tsClientData struct {
+	Version string
+	Models  []*tsModel
+	Methods []*tsMethod
+}
+
+type tsModel struct {
+	Name   string
+	Table  string
+	Fields []*tsField
+}
+
+type tsField struct {
+	Name     string
+	Type     string
+	Optional bool
+}
+
+type tsMethod struct {
+	Name    string
+	Summary string
+	Method  string
+	Path    string
+	Args    string
+	Query   bool
+	Body    string
+	Result  string
+	Blob    bool
+}
+
+func tsModels(c *crud) []*tsModel {
+	var o []*tsModel
+	for _, t := range c.models() {
+		m := &tsModel{Name: modelTypeName(t.name), Table: t.name}
+		for _, col := range t.columns {
+			m.Fields = append(m.Fields, &tsField{
+				Name:     tsKey(col.name),
+				Type:     tsType(col.typ),
+				Optional: !col.notNull,
+			})
+		}
+		if t.hasOne != nil {
+			m.Fields = append(m.Fields, &tsField{
+				Name:     tsKey(inflection.Singular(t.hasOne.destTable)),
+				Type:     modelTypeName(t.hasOne.destTable),
+				Optional: true,
+			})
+		}
+		o = append(o, m)
+	}
+	return o
+}
+
+func tsKey(name string) string {
+	if tsIdent.MatchString(name) {
+		return name
+	}
+	return jsonQuote(name)
+}
+
+// tsType maps column types to the way they travel as json, time is a RFC 3339
+// string and blobs are base64 strings.
+func tsType(typ ql.Type) string {
+	switch typ {
+	case ql.Bool:
+		return "boolean"
+	case ql.Int8, ql.Int16, ql.Int32, ql.Int64,
+		ql.Uint8, ql.Uint16, ql.Uint32, ql.Uint64,
+		ql.Float32, ql.Float64, ql.Duration, ql.BigInt:
+		return "number"
+	case ql.String, ql.Blob, ql.Time, ql.BigRat:
+		return "string"
+	}
+	return "unknown"
+}
+
+func tsSchemaType(s *jsonSchema) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Ref != "" {
+		return modelTypeName(path.Base(s.Ref))
+	}
+	if s.Format == "binary" {
+		return "Blob"
+	}
+	typ, _ := s.Type.(string)
+	switch typ {
+	case "array":
+		return tsSchemaType(s.Items) + "[]"
+	case "object":
+		if len(s.Properties) == 0 {
+			return "Record<string, unknown>"
+		}
+		var keys []string
+		for k := range s.Properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var fields []string
+		for _, k := range keys {
+			fields = append(fields, tsKey(k)+": "+tsSchemaType(s.Properties[k]))
+		}
+		return "{ " + strings.Join(fields, "; ") + " }"
+	case "integer", "number":
+		return "number"
+	case "string", "boolean":
+		return typ
+	}
+	return "unknown"
+}
+
+func tsMethods(s *service) []*tsMethod {
+	var o []*tsMethod
+	for _, e := range s.Endpoints {
+		m := &tsMethod{
+			Name:    operationID(e.Method, e.Path),
+			Summary: strings.ToUpper(e.Method) + " " + e.Path,
+			Method:  strings.ToUpper(e.Method),
+			Result:  tsSchemaType(e.response),
+			Blob:    e.response != nil && e.response.Format == "binary",
+		}
+		var args []string
+		parts := strings.Split(e.Path, "/")
+		for k, v := range parts {
+			if !strings.HasPrefix(v, ":") {
+				continue
+			}
+			name := v[1:]
+			typ := "string"
+			for _, p := range e.Params {
+				if p.Name == name {
+					typ = tsSchemaType(paramSchema(p.Type))
+				}
+			}
+			args = append(args, name+": "+typ)
+			parts[k] = "${encodeURIComponent(String(" + name + "))}"
+		}
+		m.Path = strings.Join(parts, "/")
+		if e.request != nil {
+			m.Body = "json"
+			typ := tsSchemaType(e.request)
+			if strings.HasSuffix(e.Path, "/_import") {
+				m.Body = "ndjson"
+				typ += "[]"
+			}
+			args = append(args, "body: "+typ)
+		}
+		for _, p := range e.Params {
+			if !pathParam(e, p) {
+				m.Query = true
+			}
+		}
+		if m.Query {
+			args = append(args, "query: Query = {}")
+		}
+		m.Args = strings.Join(args, ", ")
+		o = append(o, m)
+	}
+	return o
+}
+
+func genTSClient(c *crud) ([]byte, error) {
+	s, err := c.service()
+	if err != nil {
+		return nil, err
+	}
+	d := tsClientData{
+		Version: activeVersion,
+		Models:  tsModels(c),
+		Methods: tsMethods(s),
+	}
+	var buf bytes.Buffer
+	err = tsClientTpl.Execute(&buf, d)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *api) tsClient(w http.ResponseWriter, r *http.Request) {
+	b, err := genTSClient(a.c)
+	if err != nil {
+		jsonErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	textOk(w, string(b))
+}
+
+const tsClientSrc = `// Code generated by qlfu. DO NOT EDIT.
+{{range .Models}}
+/** A record of the {{.Table}} table. */
+export interface {{.Name}} {
+{{- range .Fields}}
+  {{.Name}}{{if .Optional}}?{{end}}: {{.Type}};
+{{- end}}
+}
+{{end}}
+export type Query = Record<string, string | number | boolean>;
+
+export class ApiError extends Error {
+  constructor(
+    public status: number,
+    message: string,
+    public fields?: Record<string, string>,
+  ) {
+    super(message);
+  }
+}
+
+export class Client {
+  constructor(
+    public baseURL = "http://localhost:8090",
+    public init: RequestInit = {},
+  ) {
+    this.baseURL = baseURL.replace(/\/$/, "");
+  }
+
+  private async request(method: string, path: string, query: Query = {}, body?: string, type?: string): Promise<Response> {
+    const params = new URLSearchParams();
+    for (const [k, v] of Object.entries(query)) {
+      params.set(k, String(v));
+    }
+    const q = params.toString();
+    const headers = new Headers(this.init.headers);
+    headers.set("Accept", "application/json");
+    if (type) {
+      headers.set("Content-Type", type);
+    }
+    const res = await fetch(this.baseURL + "/v{{.Version}}" + path + (q ? "?" + q : ""), {
+      ...this.init,
+      method,
+      headers,
+      body,
+    });
+    if (!res.ok) {
+      const text = await res.text();
+      let e: { error?: string; fields?: Record<string, string> } = {};
+      try {
+        e = JSON.parse(text);
+      } catch {
+        // not a json error
+      }
+      throw new ApiError(res.status, e.error || text.trim() || res.statusText, e.fields);
+    }
+    return res;
+  }
+{{range .Methods}}
+  /** {{.Summary}} */
+  async {{.Name}}({{.Args}}): Promise<{{.Result}}> {
+    const res = await this.request("{{.Method}}", ` + "`" + `{{.Path}}` + "`" + `, {{if .Query}}query{{else}}{}{{end}}
+      {{- if eq .Body "json"}}, JSON.stringify(body), "application/json"
+      {{- else if eq .Body "ndjson"}}, body.map((v) => JSON.stringify(v)).join("\n"), "application/x-ndjson"
+      {{- end}});
+    return {{if .Blob}}res.blob(){{else}}res.json(){{end}};
+  }
+{{end -}}
+}
+`
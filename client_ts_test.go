@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTSSchemaType(t *testing.T) {
+	sample := []struct {
+		s   *jsonSchema
+		typ string
+	}{
+		{nil, "unknown"},
+		{&jsonSchema{Ref: openAPIRefs + "users"}, "User"},
+		{&jsonSchema{Type: "array", Items: &jsonSchema{Ref: openAPIRefs + "users"}}, "User[]"},
+		{&jsonSchema{Type: "string", Format: "binary"}, "Blob"},
+		{&jsonSchema{Type: "object"}, "Record<string, unknown>"},
+		{objectSchema(map[string]*jsonSchema{
+			"op":     {Type: "string"},
+			"count":  {Type: "integer"},
+			"x-size": {Type: "number"},
+		}), `{ count: number; op: string; "x-size": number }`},
+	}
+	for _, v := range sample {
+		if typ := tsSchemaType(v.s); typ != v.typ {
+			t.Errorf("expected %s got %s", v.typ, typ)
+		}
+	}
+}
+
+func TestGenTSClient(t *testing.T) {
+	c := testCrud(t, ddlSample+`
+create table posts (
+	title      string not null,
+	cover      blob,
+	created_at time,
+	deleted_at time,
+);
+`)
+	b, err := genTSClient(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	for _, v := range []string{
+		"export interface User {\n  username: string;\n  age?: number;\n  email?: string;\n}",
+		"  users_id?: number;\n  user?: User;\n",
+		"  cover?: string;\n  created_at?: string;\n",
+		"async postUsers(body: User): Promise<User>",
+		"async getUsers(query: Query = {}): Promise<User[]>",
+		"async putUsersID(id: number, body: User): Promise<User>",
+		"async deleteUsersID(id: number): Promise<{ status: string }>",
+		"async postUsersImport(body: User[], query: Query = {})",
+		`"application/x-ndjson"`,
+		"async getPostsIDCover(id: number): Promise<Blob>",
+		"return res.blob();",
+		"async postPostsIDRestore(id: number): Promise<Post>",
+		"`/users/${encodeURIComponent(String(id))}`",
+	} {
+		if !strings.Contains(s, v) {
+			t.Errorf("expected %s in\n%s", v, s)
+		}
+	}
+}
+
+func TestAPI_tsClient(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"user":{"username":"gernest","joined":"2017-01-01T00:00:00Z"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/_client/ts", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	s := w.Body.String()
+	for _, v := range []string{
+		"export interface User {",
+		"  joined?: string;",
+		"export class Client {",
+	} {
+		if !strings.Contains(s, v) {
+			t.Errorf("expected %s in\n%s", v, s)
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"sync"
 	"text/template"
 
 	"fmt"
@@ -18,6 +17,8 @@ import (
 
 	"sort"
 
+	"strings"
+
 	"time"
 
 	"github.com/cznic/ql"
@@ -31,12 +32,23 @@ const activeVersion = "1"
 const methodGet = "get"
 const methodPost = "post"
 const methodPut = "put"
+const methodPatch = "patch"
+const methodDelete = "delete"
+
+const opInserted = "inserted"
+const opUpdated = "updated"
+
+var errAmbiguousMatch = errors.New("more than one record matches the given columns")
+var errNotFound = errors.New("no records found")
+var errPreconditionFailed = errors.New("record has been modified")
+var errBatchTooLarge = fmt.Errorf("at most %d records can be upserted at once", defaultImportBatch)
 
 func init() {
 	funcs := make(template.FuncMap)
 	funcs["incr"] = func(i int) int {
 		return i + 1
 	}
+	funcs["join"] = strings.Join
 	t, err := template.New("qlfu").Funcs(funcs).Parse(qlfuTpl)
 	if err != nil {
 		log.Fatal(err)
@@ -45,13 +57,14 @@ func init() {
 }
 
 type crud struct {
-	db     *ql.DB
-	mu     sync.Mutex
-	schema *dbSchema
+	db      *ql.DB
+	schema  *dbSchema
+	changes *changeFeed
+	hooks   *crudHooks
 }
 
 func newCrud(db *ql.DB) (*crud, error) {
-	c := &crud{db: db}
+	c := &crud{db: db, changes: newChangeFeed(), hooks: newCrudHooks()}
 	if err := c.load(); err != nil {
 		return nil, err
 	}
@@ -75,22 +88,32 @@ func (m modelProps) propProperty(name string) (modelProps, bool) {
 	return nil, false
 }
 func (c *crud) create(model string, props modelProps) (modelProps, error) {
+	var o modelProps
+	err := c.inTx(func(tx *crudTx) error {
+		var err error
+		o, err = c.createTx(tx, model, props)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (c *crud) createTx(tx *crudTx, model string, props modelProps) (modelProps, error) {
 	var f []*field
 	t, ok := c.schema.tables[model]
 	if !ok {
 		return nil, fmt.Errorf("model %s not found", model)
 	}
-	if t.related {
-		if t.hasOne != nil {
-			if one, ok := c.findHasOneProps(t.hasOne.destTable, props); ok {
-				rp, err := c.create(t.hasOne.destTable, one)
-				if err != nil {
-					return nil, err
-				}
-				fk := t.hasOne.destTable + "_id"
-				props[fk] = rp["id"]
-			}
-		}
+	h := &hookContext{Model: model, Op: changeCreate, Props: props, tx: tx}
+	err := c.runBefore(h)
+	if err != nil {
+		return nil, err
+	}
+	err = c.createRelated(tx, t, props)
+	if err != nil {
+		return nil, err
 	}
 	for k, v := range props {
 		if _, ok := t.colID(k); ok {
@@ -104,7 +127,7 @@ func (c *crud) create(model string, props modelProps) (modelProps, error) {
 	ctx["model"] = model
 	ctx["fields"] = f
 	var buf bytes.Buffer
-	err := tpl.ExecuteTemplate(&buf, "create", ctx)
+	err = tpl.ExecuteTemplate(&buf, "create", ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -112,12 +135,11 @@ func (c *crud) create(model string, props modelProps) (modelProps, error) {
 	for _, fv := range f {
 		v = append(v, fv.value)
 	}
-	qctx := ql.NewRWCtx()
-	_, _, err = c.db.Run(qctx, buf.String(), v...)
+	_, err = tx.run(buf.String(), v...)
 	if err != nil {
-
 		return nil, err
 	}
+	id := tx.ctx.LastInsertID
 
 	nctx := make(map[string]interface{})
 	nctx["id"] = "id"
@@ -129,14 +151,315 @@ func (c *crud) create(model string, props modelProps) (modelProps, error) {
 		return nil, err
 	}
 
-	_, _, err = c.db.Run(ql.NewRWCtx(), buf.String(), qctx.LastInsertID)
+	_, err = tx.run(buf.String(), id)
+	if err != nil {
+		return nil, err
+	}
+	props["id"] = id
+	h.ID = id
+	err = c.runAfter(h)
 	if err != nil {
 		return nil, err
 	}
-	props["id"] = qctx.LastInsertID
+	tx.changed(model, id, changeCreate, nil)
 	return props, nil
 }
 
+func (c *crud) createRelated(tx *crudTx, t *table, props modelProps) error {
+	if t.related {
+		if t.hasOne != nil {
+			if one, ok := c.findHasOneProps(t.hasOne.destTable, props); ok {
+				rp, err := c.createTx(tx, t.hasOne.destTable, one)
+				if err != nil {
+					return err
+				}
+				fk := t.hasOne.destTable + "_id"
+				props[fk] = rp["id"]
+			}
+		}
+	}
+	return nil
+}
+
+type upsertResult struct {
+	Op     string     `json:"op"`
+	Record modelProps `json:"record"`
+}
+
+func (c *crud) upsert(model string, on []string, props modelProps) (*upsertResult, error) {
+	o, err := c.upsertAll(model, on, []modelProps{props})
+	if err != nil {
+		return nil, err
+	}
+	return o[0], nil
+}
+
+// upsertAll upserts every record of list in a single transaction, nothing is
+// applied when one of them fails.
+func (c *crud) upsertAll(model string, on []string, list []modelProps) ([]*upsertResult, error) {
+	if _, ok := c.schema.tables[model]; !ok {
+		return nil, fmt.Errorf("model %s not found", model)
+	}
+	if len(on) == 0 {
+		return nil, errors.New("at least one column is required to match records")
+	}
+	if len(list) > defaultImportBatch {
+		return nil, errBatchTooLarge
+	}
+	var o []*upsertResult
+	err := c.inTx(func(tx *crudTx) error {
+		for _, props := range list {
+			r, err := c.upsertTx(tx, model, on, props)
+			if err != nil {
+				return err
+			}
+			o = append(o, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (c *crud) upsertTx(tx *crudTx, model string, on []string, props modelProps) (*upsertResult, error) {
+	t := c.schema.tables[model]
+	var keys []*field
+	verr := &validationError{}
+	for _, k := range on {
+		if _, ok := t.colID(k); !ok {
+			verr.add(k, "unknown column")
+			continue
+		}
+		v, ok := props[k]
+		if !ok || v == nil {
+			verr.add(k, "missing value")
+			continue
+		}
+		keys = append(keys, &field{Name: k, value: v})
+	}
+	if verr.failed() {
+		return nil, verr
+	}
+	ids, err := c.findBy(tx.ctx, model, keys)
+	if err != nil {
+		return nil, err
+	}
+	o := &upsertResult{}
+	switch len(ids) {
+	case 0:
+		o.Op = opInserted
+		o.Record, err = c.createTx(tx, model, props)
+	case 1:
+		o.Op = opUpdated
+		o.Record, err = c.updateTx(tx, model, ids[0], props)
+	default:
+		return nil, errAmbiguousMatch
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (c *crud) findBy(qctx *ql.TCtx, model string, keys []*field) ([]int64, error) {
+	ctx := make(map[string]interface{})
+	ctx["model"] = model
+	ctx["fields"] = keys
+	ctx["where"] = c.scopeWhere(model, scopeLive)
+	var buf bytes.Buffer
+	err := tpl.ExecuteTemplate(&buf, "find_by", ctx)
+	if err != nil {
+		return nil, err
+	}
+	var v []interface{}
+	for _, k := range keys {
+		v = append(v, k.value)
+	}
+	rs, _, err := c.db.Run(qctx, buf.String(), v...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, r := range rs {
+		err = r.Do(false, func(data []interface{}) (bool, error) {
+			if id, ok := data[0].(int64); ok {
+				ids = append(ids, id)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func (c *crud) update(model string, id int64, props modelProps) (modelProps, error) {
+	var o modelProps
+	err := c.inTx(func(tx *crudTx) error {
+		var err error
+		o, err = c.updateTx(tx, model, id, props)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (c *crud) updateTx(tx *crudTx, model string, id int64, props modelProps) (modelProps, error) {
+	var f []*field
+	t, ok := c.schema.tables[model]
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", model)
+	}
+	h := &hookContext{Model: model, Op: changeUpdate, ID: id, Props: props, tx: tx}
+	err := c.runBefore(h)
+	if err != nil {
+		return nil, err
+	}
+	err = c.createRelated(tx, t, props)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range props {
+		if k == "id" {
+			continue
+		}
+		if _, ok := t.colID(k); ok {
+			f = append(f, &field{
+				Name:  k,
+				value: v,
+			})
+		}
+	}
+	if len(f) > 0 {
+		ctx := make(map[string]interface{})
+		ctx["model"] = model
+		ctx["fields"] = f
+		var buf bytes.Buffer
+		err = tpl.ExecuteTemplate(&buf, "update", ctx)
+		if err != nil {
+			return nil, err
+		}
+		var v []interface{}
+		for _, fv := range f {
+			v = append(v, fv.value)
+		}
+		v = append(v, id)
+		_, err = tx.run(buf.String(), v...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	o, err := c.getByIDCtx(tx.ctx, model, id, scopeLive)
+	if err != nil {
+		return nil, err
+	}
+	if len(o) == 0 {
+		return nil, fmt.Errorf("%s %d not found", model, id)
+	}
+	h.Props = o[0]
+	err = c.runAfter(h)
+	if err != nil {
+		return nil, err
+	}
+	if len(f) > 0 {
+		tx.changed(model, id, changeUpdate, o[0])
+	}
+	return o[0], nil
+}
+
+func (c *crud) updateByID(model string, id int64, props modelProps, ifMatch string) (modelProps, error) {
+	var o modelProps
+	err := c.inTx(func(tx *crudTx) error {
+		err := c.checkMatch(tx, model, id, ifMatch)
+		if err != nil {
+			return err
+		}
+		o, err = c.updateTx(tx, model, id, props)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (c *crud) deleteByID(model string, id int64, ifMatch string) error {
+	return c.inTx(func(tx *crudTx) error {
+		err := c.checkMatch(tx, model, id, ifMatch)
+		if err != nil {
+			return err
+		}
+		return c.deleteTx(tx, model, id)
+	})
+}
+
+func (c *crud) deleteTx(tx *crudTx, model string, id int64) error {
+	ctx := make(map[string]interface{})
+	ctx["model"] = model
+	name := "delete_by_id"
+	if c.softDeletes(model) {
+		name = "soft_delete_by_id"
+	}
+	var buf bytes.Buffer
+	err := tpl.ExecuteTemplate(&buf, name, ctx)
+	if err != nil {
+		return err
+	}
+	o, err := c.getByIDCtx(tx.ctx, model, id, scopeLive)
+	if err != nil {
+		return err
+	}
+	h := &hookContext{Model: model, Op: changeDelete, ID: id, tx: tx}
+	if len(o) > 0 {
+		h.Props = o[0]
+	}
+	err = c.runBefore(h)
+	if err != nil {
+		return err
+	}
+	_, err = tx.run(buf.String(), id)
+	if err != nil {
+		return err
+	}
+	err = c.runAfter(h)
+	if err != nil {
+		return err
+	}
+	if h.Props != nil {
+		tx.changed(model, id, changeDelete, h.Props)
+	}
+	return nil
+}
+
+// checkMatch reads model id in tx, so no other write can change it before the
+// operation does.
+func (c *crud) checkMatch(tx *crudTx, model string, id int64, ifMatch string) error {
+	o, err := c.getByIDCtx(tx.ctx, model, id, scopeLive)
+	if err != nil {
+		return err
+	}
+	if len(o) == 0 {
+		return errNotFound
+	}
+	err = c.runAfter(&hookContext{Model: model, Op: hookRead, ID: id, Props: o[0], tx: tx})
+	if err != nil {
+		return err
+	}
+	if ifMatch != "" && !matchETag(ifMatch, etag(o[0])) {
+		return errPreconditionFailed
+	}
+	return nil
+}
+
+func isRelationProp(model, name string) bool {
+	return name == model || name == inflection.Singular(model)
+}
+
 func (c *crud) findHasOneProps(model string, props modelProps) (modelProps, bool) {
 	if o, ok := props.propProperty(model); ok {
 		return o, true
@@ -145,55 +468,71 @@ func (c *crud) findHasOneProps(model string, props modelProps) (modelProps, bool
 }
 
 func (c *crud) getAll(model string) ([]modelProps, error) {
-	ctx := make(map[string]interface{})
-	ctx["model"] = model
-	var buf bytes.Buffer
-	err := tpl.ExecuteTemplate(&buf, "get_all", ctx)
+	return c.list(model, &listQuery{})
+}
+
+func (c *crud) list(model string, q *listQuery) ([]modelProps, error) {
+	var o []modelProps
+	err := c.each(model, q, func(names []string, data []interface{}) (bool, error) {
+		p := make(modelProps)
+		for k, value := range data {
+			p[names[k]] = value
+		}
+		o = append(o, p)
+		return true, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	rs, _, err := c.db.Run(ql.NewRWCtx(), buf.String())
+	return o, nil
+}
+
+func (c *crud) getByID(model string, id int64, scope int) ([]modelProps, error) {
+	err := c.beforeRead(model, id)
 	if err != nil {
 		return nil, err
 	}
-	var o []modelProps
-	for _, v := range rs {
-		names, err := v.Fields()
+	o, err := c.getByIDIn(model, id, scope)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range o {
+		err = c.afterRead(model, p)
 		if err != nil {
 			return nil, err
 		}
-		_ = v.Do(false, func(data []interface{}) (bool, error) {
-			p := make(modelProps)
-			for k, value := range data {
-				p[names[k]] = value
-			}
-			o = append(o, p)
-			return true, nil
-		})
 	}
-
 	return o, nil
 }
 
-func (c *crud) getByID(model string, id int64) ([]modelProps, error) {
+func (c *crud) getByIDIn(model string, id int64, scope int) ([]modelProps, error) {
+	return c.getByIDCtx(ql.NewRWCtx(), model, id, scope)
+}
+
+func (c *crud) getByIDCtx(qctx *ql.TCtx, model string, id int64, scope int) ([]modelProps, error) {
 	ctx := make(map[string]interface{})
 	ctx["model"] = model
+	ctx["where"] = c.scopeWhere(model, scope)
 	var buf bytes.Buffer
 	err := tpl.ExecuteTemplate(&buf, "get_by_id", ctx)
 	if err != nil {
 		return nil, err
 	}
-	rs, _, err := c.db.Run(ql.NewRWCtx(), buf.String(), id)
+	rs, _, err := c.db.Run(qctx, buf.String(), id)
 	if err != nil {
 		return nil, err
 	}
+	return collect(rs)
+}
+
+func collect(rs []ql.Recordset) ([]modelProps, error) {
 	var o []modelProps
 	for _, v := range rs {
 		names, err := v.Fields()
 		if err != nil {
 			return nil, err
 		}
-		_ = v.Do(false, func(data []interface{}) (bool, error) {
+		err = v.Do(false, func(data []interface{}) (bool, error) {
 			p := make(modelProps)
 			for k, value := range data {
 				p[names[k]] = value
@@ -201,27 +540,47 @@ func (c *crud) getByID(model string, id int64) ([]modelProps, error) {
 			o = append(o, p)
 			return true, nil
 		})
+		if err != nil {
+			return nil, err
+		}
 	}
 	return o, nil
 }
 
 const qlfuTpl = `
 {{define "create"}}
-begin transaction;
   insert into {{.model}} ({{range $k,$v:=.fields}}{{if eq $k 0}}{{$v.Name}}{{else}}, {{$v.Name}}{{end}}{{end}})
   values ({{range $k,$v:=.fields}}{{if eq $k 0}}${{incr $k}}{{else}}, ${{incr $k}}{{end}}{{end}});
-commit;
 {{end}}
 {{define "update_last_id"}}
-begin transaction;
   update {{.model}} {{.id}}=$1 where id()=$1 ;
-commit;
+{{end}}
+{{define "find_by"}}
+  select id from {{.model}} where {{range $k,$v:=.fields}}{{if eq $k 0}}{{$v.Name}}==${{incr $k}}{{else}} && {{$v.Name}}==${{incr $k}}{{end}}{{end}}{{if .where}} && {{.where}}{{end}};
+{{end}}
+{{define "update"}}
+  update {{.model}} {{range $k,$v:=.fields}}{{if eq $k 0}}{{$v.Name}}=${{incr $k}}{{else}}, {{$v.Name}}=${{incr $k}}{{end}}{{end}}
+  where id==${{incr (len .fields)}};
+{{end}}
+{{define "delete_by_id"}}
+  delete from {{.model}} where id==$1;
+{{end}}
+{{define "soft_delete_by_id"}}
+  update {{.model}} deleted_at=now() where id==$1 && deleted_at IS NULL;
 {{end}}
 {{define "get_by_id"}}
-  select * from {{.model}} where id=$1;
+  select * from {{.model}} where id=$1{{if .where}} && {{.where}}{{end}};
+{{end}}
+{{define "count"}}
+  select count(*) AS count from {{.model}}{{if .where}} where {{.where}}{{end}}
+{{end}}
+{{define "aggregate"}}
+  select {{join .fields ", "}} from {{.model}}{{if .where}} where {{.where}}{{end}}{{if .group}}
+  group by {{join .group ", "}}
+  order by {{join .group ", "}}{{end}}
 {{end}}
 {{define "get_all"}}
-  select * from {{.model}}
+  select * from {{.model}}{{if .where}} where {{.where}}{{end}}{{if .limit}} limit {{.limit}}{{end}}{{if .offset}} offset {{.offset}}{{end}}
 {{end}}
 `
 
@@ -248,19 +607,113 @@ func (c *crud) service() (*service, error) {
 	}
 	for _, m := range c.models() {
 		s.Endpoints = append(s.Endpoints, endpoint{
-			Path:    "/" + m.name,
-			Method:  methodPost,
-			Payload: samplePayload(m, true),
-			handler: c.createHandler(m.name),
+			Path:     "/" + m.name,
+			Method:   methodPost,
+			Payload:  samplePayload(m, true),
+			handler:  c.createHandler(m.name),
+			request:  modelRef(m),
+			response: modelRef(m),
 		})
 		s.Endpoints = append(s.Endpoints, endpoint{
-			Path:    "/" + m.name,
+			Path:     "/" + m.name,
+			Method:   methodGet,
+			Params:   append(filterParams(m), pageParams(m)...),
+			handler:  c.getAllHandler(m.name),
+			response: modelList(m),
+		})
+		s.Endpoints = append(s.Endpoints, endpoint{
+			Path:     "/" + m.name + "/_import",
+			Method:   methodPost,
+			Params:   importParams(m),
+			Payload:  samplePayload(m, true),
+			handler:  c.importHandler(m.name),
+			request:  modelRef(m),
+			response: importResultSchema(),
+		})
+		s.Endpoints = append(s.Endpoints, endpoint{
+			Path:     "/" + m.name + "/_count",
+			Method:   methodGet,
+			Params:   filterParams(m),
+			handler:  c.countHandler(m.name),
+			response: objectSchema(map[string]*jsonSchema{"count": {Type: "integer"}}),
+		})
+		s.Endpoints = append(s.Endpoints, endpoint{
+			Path:     "/" + m.name + "/_aggregate",
+			Method:   methodGet,
+			Params:   append(aggregateParams(m), filterParams(m)...),
+			handler:  c.aggregateHandler(m.name),
+			response: &jsonSchema{Type: "array", Items: &jsonSchema{Type: "object"}},
+		})
+		s.Endpoints = append(s.Endpoints, endpoint{
+			Path:    "/" + m.name + "/_schema",
 			Method:  methodGet,
-			handler: c.getAllHandler(m.name),
+			handler: c.jsonSchemaHandler(m.name),
+		})
+		s.Endpoints = append(s.Endpoints, endpoint{
+			Path:   "/" + m.name,
+			Method: methodPut,
+			Params: []param{
+				{
+					Name:     "on",
+					Type:     "string",
+					Desc:     "comma separated unique columns used to match existing " + m.name + " objects",
+					Required: true,
+				},
+			},
+			Payload:  samplePayload(m, false),
+			handler:  c.upsertHandler(m.name),
+			request:  modelRef(m),
+			response: upsertResultSchema(m),
 		})
 		s.Endpoints = append(s.Endpoints, endpoint{
 			Path:   "/" + m.name + "/:id",
 			Method: methodGet,
+			Params: append([]param{
+				{
+					Name:    "id",
+					Type:    "int64",
+					Desc:    "the id of " + m.name + " object",
+					Default: 1,
+				},
+			}, scopeParams(m)...),
+			handler:  c.getByIDHandler(m.name),
+			response: modelList(m),
+		})
+		for _, col := range blobColumns(m) {
+			s.Endpoints = append(s.Endpoints, endpoint{
+				Path:   "/" + m.name + "/:id/" + col.name,
+				Method: methodGet,
+				Params: []param{
+					{
+						Name:    "id",
+						Type:    "int64",
+						Desc:    "the id of " + m.name + " object",
+						Default: 1,
+					},
+				},
+				handler:  c.blobHandler(m.name, col.name),
+				response: &jsonSchema{Type: "string", Format: "binary"},
+			})
+		}
+		s.Endpoints = append(s.Endpoints, endpoint{
+			Path:   "/" + m.name + "/:id",
+			Method: methodPut,
+			Params: []param{
+				{
+					Name:    "id",
+					Type:    "int64",
+					Desc:    "the id of " + m.name + " object",
+					Default: 1,
+				},
+			},
+			Payload:  samplePayload(m, true),
+			handler:  c.updateHandler(m.name),
+			request:  modelRef(m),
+			response: modelRef(m),
+		})
+		s.Endpoints = append(s.Endpoints, endpoint{
+			Path:   "/" + m.name + "/:id",
+			Method: methodDelete,
 			Params: []param{
 				{
 					Name:    "id",
@@ -269,9 +722,37 @@ func (c *crud) service() (*service, error) {
 					Default: 1,
 				},
 			},
-			handler: c.getByIDHandler(m.name),
+			handler:  c.deleteHandler(m.name),
+			response: objectSchema(map[string]*jsonSchema{"status": {Type: "string"}}),
 		})
+		if m.softDelete {
+			s.Endpoints = append(s.Endpoints, endpoint{
+				Path:     "/" + m.name + "/_trash",
+				Method:   methodGet,
+				handler:  c.trashHandler(m.name),
+				response: modelList(m),
+			})
+			s.Endpoints = append(s.Endpoints, endpoint{
+				Path:   "/" + m.name + "/:id/restore",
+				Method: methodPost,
+				Params: []param{
+					{
+						Name:    "id",
+						Type:    "int64",
+						Desc:    "the id of deleted " + m.name + " object",
+						Default: 1,
+					},
+				},
+				handler:  c.restoreHandler(m.name),
+				response: modelRef(m),
+			})
+		}
 	}
+	s.Endpoints = append(s.Endpoints, endpoint{
+		Path:    "/_schemas",
+		Method:  methodGet,
+		handler: c.jsonSchemasHandler,
+	})
 	return s, nil
 }
 
@@ -286,38 +767,75 @@ func (c *crud) models() tableList {
 
 func (c *crud) createHandler(model string) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		prop := make(modelProps)
-		b, err := ioutil.ReadAll(r.Body)
+		props, batch, err := propsFromRequest(r)
 		if err != nil {
 			jsonErr(w, err, http.StatusBadRequest)
 			return
 		}
-		err = json.Unmarshal(b, &prop)
+		if batch {
+			jsonErr(w, errors.New("expected a json object"), http.StatusBadRequest)
+			return
+		}
+		prop := props[0]
+		err = c.validate(model, prop, queryBool(r, "strict"))
 		if err != nil {
-			jsonErr(w, err, http.StatusInternalServerError)
+			jsonErr(w, err, http.StatusUnprocessableEntity)
 			return
 		}
 		o, err := c.create(model, prop)
 		if err != nil {
-			jsonErr(w, err, http.StatusInternalServerError)
+			jsonErr(w, err, crudErrCode(err))
 			return
 		}
 		jsonRes(w, o)
 	}
 }
 
-func (c *crud) getAllHandler(model string) func(http.ResponseWriter, *http.Request) {
+func (c *crud) upsertHandler(model string) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		o, err := c.getAll(model)
+		on := splitList(r.URL.Query().Get("on"))
+		if len(on) == 0 {
+			jsonErr(w, errors.New("missing on query parameter"), http.StatusBadRequest)
+			return
+		}
+		props, batch, err := propsFromRequest(r)
 		if err != nil {
-			jsonErr(w, err, http.StatusInternalServerError)
+			jsonErr(w, err, http.StatusBadRequest)
 			return
 		}
-		if o == nil {
-			jsonErr(w, errors.New("no records found"), http.StatusNotFound)
+		if len(props) > defaultImportBatch {
+			jsonErr(w, errBatchTooLarge, http.StatusRequestEntityTooLarge)
 			return
 		}
-		jsonRes(w, o)
+		strict := queryBool(r, "strict")
+		for _, prop := range props {
+			err = c.validate(model, prop, strict)
+			if err != nil {
+				jsonErr(w, err, http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		o, err := c.upsertAll(model, on, props)
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		if batch {
+			jsonRes(w, o)
+			return
+		}
+		jsonRes(w, o[0])
+	}
+}
+
+func (c *crud) getAllHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := c.listQueryFrom(model, r, true)
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		c.streamList(w, r, model, q)
 	}
 }
 func (c *crud) getByIDHandler(model string) func(http.ResponseWriter, *http.Request) {
@@ -329,19 +847,117 @@ func (c *crud) getByIDHandler(model string) func(http.ResponseWriter, *http.Requ
 			jsonErr(w, err, http.StatusInternalServerError)
 			return
 		}
-		o, err := c.getByID(model, int64(i))
+		o, err := c.getByID(model, int64(i), scopeFrom(c.schema.tables[model], r.URL.Query()))
 		if err != nil {
-			jsonErr(w, err, http.StatusInternalServerError)
+			jsonErr(w, err, crudErrCode(err))
 			return
 		}
 		if o == nil {
-			jsonErr(w, errors.New("no records found"), http.StatusNotFound)
+			jsonErr(w, errNotFound, http.StatusNotFound)
+			return
+		}
+		if notModified(w, r, etag(o[0])) {
+			return
+		}
+		jsonRes(w, o)
+	}
+}
+
+func (c *crud) updateHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := idParam(r)
+		if err != nil {
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+		props, batch, err := propsFromRequest(r)
+		if err != nil {
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+		if batch {
+			jsonErr(w, errors.New("expected a json object"), http.StatusBadRequest)
+			return
+		}
+		prop := props[0]
+		err = c.validate(model, prop, queryBool(r, "strict"))
+		if err != nil {
+			jsonErr(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+		o, err := c.updateByID(model, id, prop, r.Header.Get("If-Match"))
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
 			return
 		}
+		w.Header().Set("ETag", etag(o))
 		jsonRes(w, o)
 	}
 }
 
+func (c *crud) deleteHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := idParam(r)
+		if err != nil {
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+		err = c.deleteByID(model, id, r.Header.Get("If-Match"))
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		jsonOk(w)
+	}
+}
+
+func idParam(r *http.Request) (int64, error) {
+	p := alien.GetParams(r)
+	return strconv.ParseInt(p.Get("id"), 10, 64)
+}
+
+func propsFromRequest(r *http.Request) ([]modelProps, bool, error) {
+	if isMultipart(r) {
+		p, err := multipartProps(r)
+		if err != nil {
+			return nil, false, err
+		}
+		return []modelProps{p}, false, nil
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return decodeProps(b)
+}
+
+func queryBool(r *http.Request, name string) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get(name))
+	return v
+}
+
+func crudErrCode(err error) int {
+	switch v := err.(type) {
+	case *validationError:
+		return http.StatusUnprocessableEntity
+	case *hookError:
+		return v.code
+	}
+	switch err {
+	case errPaging:
+		return http.StatusBadRequest
+	case errAmbiguousMatch:
+		return http.StatusConflict
+	case errNotFound:
+		return http.StatusNotFound
+	case errPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case errBatchTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
 func samplePayload(t *table, omitID bool) string {
 	o := make(modelProps)
 	for _, c := range t.columns {
@@ -365,6 +981,8 @@ func sampleValue(name string, typ ql.Type) interface{} {
 		v = 1
 	case ql.Time:
 		v = time.Now().Format(time.ANSIC)
+	case ql.Blob:
+		v = []byte(name)
 	}
 	return v
 }
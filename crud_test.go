@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -23,10 +26,8 @@ func TestTemplates_create(t *testing.T) {
 		t.Fatal(err)
 	}
 	expect := `
-begin transaction;
   insert into user (id, name, profession)
   values ($1, $2, $3);
-commit;
 	`
 	expect = strings.TrimSpace(expect)
 	v := buf.String()
@@ -62,9 +63,7 @@ func TestTemplates_update_last_id(t *testing.T) {
 		t.Fatal(err)
 	}
 	expect := `
-begin transaction;
   update users id=$1 where id()=$1 ;
-commit;
 	`
 	expect = strings.TrimSpace(expect)
 	v := buf.String()
@@ -117,3 +116,132 @@ func TestCRUD_create(t *testing.T) {
 		t.Errorf("id must be set")
 	}
 }
+
+func TestTemplates_find_by(t *testing.T) {
+	data := make(map[string]interface{})
+	data["model"] = "users"
+	data["fields"] = []*field{
+		{"email", "gernest@example.com"},
+		{"username", "gernest"},
+	}
+	var buf bytes.Buffer
+	err := tpl.ExecuteTemplate(&buf, "find_by", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "select id from users where email==$1 && username==$2;"
+	v := strings.TrimSpace(buf.String())
+	if v != expect {
+		t.Errorf("expected %s got %s", expect, v)
+	}
+}
+
+func TestTemplates_update(t *testing.T) {
+	data := make(map[string]interface{})
+	data["model"] = "users"
+	data["fields"] = []*field{
+		{"email", "gernest@example.com"},
+		{"username", "gernest"},
+	}
+	var buf bytes.Buffer
+	err := tpl.ExecuteTemplate(&buf, "update", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `
+  update users email=$1, username=$2
+  where id==$3;
+	`
+	expect = strings.TrimSpace(expect)
+	v := strings.TrimSpace(buf.String())
+	if v != expect {
+		t.Errorf("expected %s got %s", expect, v)
+	}
+}
+
+func TestCRUD_upsert(t *testing.T) {
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	_, _, err = db.Run(ql.NewRWCtx(), `
+	begin transaction;
+		create table users(
+			id int64,
+			email string,
+			name string,
+		);
+	commit;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	on := []string{"email"}
+	r, err := c.upsert("users", on, modelProps{"email": "a@example.com", "name": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Op != opInserted {
+		t.Errorf("expected %s got %s", opInserted, r.Op)
+	}
+	r, err = c.upsert("users", on, modelProps{"email": "a@example.com", "name": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Op != opUpdated {
+		t.Errorf("expected %s got %s", opUpdated, r.Op)
+	}
+	if r.Record["name"] != "b" {
+		t.Errorf("expected b got %v", r.Record["name"])
+	}
+	all, err := c.getAll("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("expected 1 record got %d", len(all))
+	}
+	_, err = c.upsert("users", []string{"missing"}, modelProps{"email": "a@example.com"})
+	if err == nil {
+		t.Error("expected an error for unknown column")
+	}
+	_, err = c.upsertAll("users", on, []modelProps{
+		{"email": "a@example.com", "name": "c"},
+		{"email": "b@example.com", "name": "b"},
+		{"name": "no email"},
+	})
+	if _, ok := err.(*validationError); !ok {
+		t.Fatalf("expected a validation error got %v", err)
+	}
+	all, err = c.getAll("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0]["name"] != "b" {
+		t.Errorf("expected the failed batch to be rolled back got %v", all)
+	}
+	big := make([]modelProps, defaultImportBatch+1)
+	for i := range big {
+		big[i] = modelProps{"email": "a@example.com"}
+	}
+	_, err = c.upsertAll("users", on, big)
+	if err != errBatchTooLarge || crudErrCode(err) != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %v got %v", errBatchTooLarge, err)
+	}
+	b, err := json.Marshal(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	c.upsertHandler("users")(w, httptest.NewRequest("PUT", "/users?on=email", bytes.NewReader(b)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected %d got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func etag(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return fmt.Sprintf(`"%x"`, sha1.Sum(b))
+}
+
+func matchETag(header, tag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+	if h := r.Header.Get("If-None-Match"); h != "" && matchETag(h, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cznic/ql"
+	"github.com/gernest/alien"
+)
+
+func TestMatchETag(t *testing.T) {
+	sample := []struct {
+		header string
+		match  bool
+	}{
+		{`"a"`, true},
+		{`W/"a"`, true},
+		{`"b", "a"`, true},
+		{`*`, true},
+		{`"b"`, false},
+	}
+	for _, v := range sample {
+		if matchETag(v.header, `"a"`) != v.match {
+			t.Errorf("expected %v for %s", v.match, v.header)
+		}
+	}
+}
+
+func TestCRUD_etag(t *testing.T) {
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	_, _, err = db.Run(ql.NewRWCtx(), `
+	begin transaction;
+		create table users(
+			id int64,
+			name string,
+		);
+	commit;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.create("users", modelProps{"name": "gernest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := alien.New()
+	_ = r.Get("/users", c.getAllHandler("users"))
+	_ = r.Get("/users/:id", c.getByIDHandler("users"))
+	_ = r.Put("/users/:id", c.updateHandler("users"))
+	_ = r.Delete("/users/:id", c.deleteHandler("users"))
+	do := func(method, path, body string, h map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		for k, v := range h {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/users/1", "", nil)
+	tag := w.Header().Get("ETag")
+	if tag == "" {
+		t.Fatal("expected ETag to be set")
+	}
+	w = do("GET", "/users/1", "", map[string]string{"If-None-Match": tag})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected %d got %d", http.StatusNotModified, w.Code)
+	}
+	if tag := do("GET", "/users", "", nil).Header().Get("ETag"); tag != "" {
+		t.Errorf("expected no ETag on a streamed list got %s", tag)
+	}
+	list := do("GET", "/users", "", map[string]string{"If-None-Match": `"stale"`}).Header().Get("ETag")
+	w = do("GET", "/users", "", map[string]string{"If-None-Match": list})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected %d got %d", http.StatusNotModified, w.Code)
+	}
+
+	w = do("PUT", "/users/1", `{"name":"geofrey"}`, map[string]string{"If-Match": `"stale"`})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected %d got %d", http.StatusPreconditionFailed, w.Code)
+	}
+	w = do("PUT", "/users/1", `{"name":"geofrey"}`, map[string]string{"If-Match": tag})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	updated := w.Header().Get("ETag")
+	if updated == tag {
+		t.Error("expected ETag to change after update")
+	}
+	w = do("GET", "/users", "", map[string]string{"If-None-Match": list})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+
+	w = do("DELETE", "/users/1", "", map[string]string{"If-Match": tag})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected %d got %d", http.StatusPreconditionFailed, w.Code)
+	}
+	w = do("DELETE", "/users/1", "", map[string]string{"If-Match": updated})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	w = do("GET", "/users/1", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %d got %d", http.StatusNotFound, w.Code)
+	}
+}
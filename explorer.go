@@ -0,0 +1,214 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+)
+
+var explorerTpl = template.Must(template.New("explorer").Parse(explorerHTML))
+
+type explorerData struct {
+	Version string
+	Models  []string
+	Service *service
+	Schemas *jsonSchema
+}
+
+func (a *api) explorer(w http.ResponseWriter, r *http.Request) {
+	d := explorerData{
+		Version: a.service.Version,
+		Service: a.service,
+		Schemas: a.c.modelsJSONSchema(),
+	}
+	for _, m := range a.c.models() {
+		d.Models = append(d.Models, m.name)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := explorerTpl.Execute(w, d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+const explorerHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>qlfu</title>
+<style>
+body{margin:0;font:14px/1.4 -apple-system,Helvetica,Arial,sans-serif;color:#222;display:flex;height:100vh}
+nav{width:220px;background:#f4f4f4;border-right:1px solid #ddd;overflow:auto;padding:8px 0}
+nav h1{font-size:16px;margin:4px 16px 12px}
+nav a{display:block;padding:4px 16px;color:#222;text-decoration:none}
+nav a.active,nav a:hover{background:#e2e2e2}
+main{flex:1;overflow:auto;padding:16px 24px}
+.endpoint{border:1px solid #ddd;border-radius:4px;margin-bottom:8px}
+.endpoint>summary{cursor:pointer;padding:6px 8px;font-family:monospace}
+.endpoint form{padding:8px;border-top:1px solid #ddd}
+.method{display:inline-block;width:60px;font-weight:bold;text-transform:uppercase}
+.get{color:#2a7ae2}.post{color:#2e9f4f}.put{color:#c67b00}.delete{color:#cc3333}
+label{display:block;margin:4px 0}
+label span{display:inline-block;width:160px;font-family:monospace}
+textarea{width:100%;height:120px;font-family:monospace}
+pre{background:#f8f8f8;border:1px solid #eee;padding:8px;overflow:auto;white-space:pre-wrap}
+.empty{color:#888}
+</style>
+</head>
+<body>
+<nav>
+<h1>qlfu v{{.Version}}</h1>
+{{range .Models}}<a href="#{{.}}" data-model="{{.}}">{{.}}</a>
+{{else}}<p class="empty" style="padding:0 16px">no models yet, POST /schema first</p>
+{{end}}</nav>
+<main id="main"><p class="empty">pick a model</p></main>
+<script>
+var version = {{.Version}};
+var service = {{.Service}};
+var schemas = {{.Schemas}};
+
+function el(tag, attrs, children) {
+  var e = document.createElement(tag);
+  for (var k in attrs || {}) {
+    if (k === "text") { e.textContent = attrs[k]; } else { e.setAttribute(k, attrs[k]); }
+  }
+  (children || []).forEach(function (c) { if (c) { e.appendChild(c); } });
+  return e;
+}
+
+function modelOf(path) {
+  return path.split("/")[1];
+}
+
+function pathParams(path) {
+  return path.split("/").filter(function (p) { return p[0] === ":"; }).map(function (p) { return p.slice(1); });
+}
+
+function fieldFor(name, prop) {
+  var type = Array.isArray(prop.type) ? prop.type[0] : prop.type;
+  var attrs = {name: name};
+  if (type === "boolean") {
+    attrs.type = "checkbox";
+  } else if (type === "integer" || type === "number") {
+    attrs.type = "number";
+    if (type === "number") { attrs.step = "any"; }
+  } else if (prop.format === "date-time") {
+    attrs.type = "datetime-local";
+  } else {
+    attrs.type = "text";
+    if (prop.contentEncoding) { attrs.placeholder = "data:;base64,..."; }
+  }
+  attrs["data-type"] = type;
+  attrs["data-format"] = prop.format || "";
+  return el("label", {}, [el("span", {text: name + " (" + (prop.format || type) + ")"}), el("input", attrs)]);
+}
+
+function bodyFields(model) {
+  var s = schemas.$defs[model];
+  if (!s) { return []; }
+  return Object.keys(s.properties).filter(function (name) {
+    return !s.properties[name].readOnly && !s.properties[name].$ref;
+  }).map(function (name) { return fieldFor(name, s.properties[name]); });
+}
+
+function readBody(form) {
+  var o = {};
+  form.querySelectorAll("[data-body] input").forEach(function (i) {
+    var t = i.getAttribute("data-type");
+    if (t === "boolean") { o[i.name] = i.checked; return; }
+    if (i.value === "") { return; }
+    if (t === "integer" || t === "number") { o[i.name] = Number(i.value); return; }
+    if (i.getAttribute("data-format") === "date-time") { o[i.name] = new Date(i.value).toISOString(); return; }
+    o[i.name] = i.value;
+  });
+  return o;
+}
+
+function buildURL(e, form) {
+  var path = e.path;
+  var q = [];
+  (e.params || []).forEach(function (p) {
+    var v = form.querySelector("[data-param='" + p.name + "']").value;
+    if (pathParams(e.path).indexOf(p.name) >= 0) {
+      path = path.replace(":" + p.name, encodeURIComponent(v));
+    } else if (v !== "") {
+      q.push(encodeURIComponent(p.name) + "=" + encodeURIComponent(v));
+    }
+  });
+  return "/v" + version + path + (q.length ? "?" + q.join("&") : "");
+}
+
+// snippet asks the server for the curl command making the same request.
+function snippet(method, url, body) {
+  var u = "/snippets" + url + (url.indexOf("?") >= 0 ? "&" : "?") + "lang=curl";
+  return fetch(u, {method: method.toUpperCase(), body: body}).then(function (res) { return res.text(); });
+}
+
+function endpointView(e) {
+  var form = el("form");
+  (e.params || []).forEach(function (p) {
+    var v = p.default === undefined || p.default === null ? "" : String(p.default);
+    form.appendChild(el("label", {}, [
+      el("span", {text: p.name + " (" + p.type + ")", title: p.desc}),
+      el("input", {"data-param": p.name, value: pathParams(e.path).indexOf(p.name) >= 0 ? v : "", placeholder: v, title: p.desc})
+    ]));
+  });
+  var raw;
+  if (e.payload) {
+    var fields = el("div", {"data-body": "1"}, bodyFields(modelOf(e.path)));
+    raw = el("textarea", {placeholder: e.payload});
+    form.appendChild(fields);
+    form.appendChild(el("p", {text: "or raw json, used when not empty"}));
+    form.appendChild(raw);
+  }
+  var out = el("pre", {text: ""});
+  var cmd = el("pre", {text: ""});
+  form.appendChild(el("button", {type: "submit", text: "send"}));
+  form.appendChild(el("h4", {text: "curl"}));
+  form.appendChild(cmd);
+  form.appendChild(el("h4", {text: "response"}));
+  form.appendChild(out);
+  form.addEventListener("submit", function (ev) {
+    ev.preventDefault();
+    var url = buildURL(e, form);
+    var opts = {method: e.method.toUpperCase(), headers: {}};
+    var body;
+    if (e.payload) {
+      body = raw.value.trim() || JSON.stringify(readBody(form));
+      opts.body = body;
+      opts.headers["Content-Type"] = "application/json";
+    }
+    cmd.textContent = "...";
+    snippet(e.method, url, body).then(function (txt) { cmd.textContent = txt; })
+      .catch(function (err) { cmd.textContent = String(err); });
+    out.textContent = "...";
+    fetch(url, opts).then(function (res) {
+      return res.text().then(function (txt) {
+        try { txt = JSON.stringify(JSON.parse(txt), null, 2); } catch (err) {}
+        out.textContent = res.status + " " + res.statusText + "\n\n" + txt;
+      });
+    }).catch(function (err) { out.textContent = String(err); });
+  });
+  return el("details", {"class": "endpoint"}, [
+    el("summary", {}, [el("span", {"class": "method " + e.method, text: e.method}), document.createTextNode(" /v" + version + e.path)]),
+    form
+  ]);
+}
+
+function show(model) {
+  var main = document.getElementById("main");
+  main.innerHTML = "";
+  main.appendChild(el("h2", {text: model}));
+  (service.Endpoints || []).filter(function (e) { return modelOf(e.path) === model; }).forEach(function (e) {
+    main.appendChild(endpointView(e));
+  });
+  document.querySelectorAll("nav a").forEach(function (a) {
+    a.className = a.getAttribute("data-model") === model ? "active" : "";
+  });
+}
+
+window.addEventListener("hashchange", function () { show(location.hash.slice(1)); });
+if (location.hash) { show(location.hash.slice(1)); }
+</script>
+</body>
+</html>
+`
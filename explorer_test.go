@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_explorer(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/ui", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "no models yet") {
+		t.Error("expected an empty explorer")
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"user":{"username":"gernest"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected html got %s", ct)
+	}
+	body := w.Body.String()
+	for _, v := range []string{`href="#users"`, `"path":"/users/:id"`, `"username"`, `"/snippets"`} {
+		if !strings.Contains(body, v) {
+			t.Errorf("expected %s in the explorer", v)
+		}
+	}
+}
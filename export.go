@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+type postmanCollection struct {
+	Info     postmanInfo    `json:"info"`
+	Item     []*postmanItem `json:"item"`
+	Variable []postmanKV    `json:"variable"`
+}
+
+type postmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []*postmanItem  `json:"item,omitempty"`
+	Request *postmanRequest `json:"request,omitempty"`
+}
+
+type postmanRequest struct {
+	Method string       `json:"method"`
+	Header []postmanKV  `json:"header"`
+	Body   *postmanBody `json:"body,omitempty"`
+	URL    postmanURL   `json:"url"`
+}
+
+type postmanBody struct {
+	Mode    string                 `json:"mode"`
+	Raw     string                 `json:"raw"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+type postmanURL struct {
+	Raw      string      `json:"raw"`
+	Host     []string    `json:"host"`
+	Path     []string    `json:"path"`
+	Query    []postmanKV `json:"query,omitempty"`
+	Variable []postmanKV `json:"variable,omitempty"`
+}
+
+type postmanKV struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+func exportFolder(e endpoint) string {
+	p := strings.Split(strings.Trim(e.Path, "/"), "/")[0]
+	if strings.HasPrefix(p, "_") {
+		return ""
+	}
+	return p
+}
+
+func paramValue(p param) string {
+	if p.Default == nil {
+		return ""
+	}
+	return fmt.Sprint(p.Default)
+}
+
+func (a *api) postman() *postmanCollection {
+	o := &postmanCollection{
+		Info: postmanInfo{Name: "qlfu", Schema: postmanSchema},
+		Variable: []postmanKV{
+			{Key: "baseURL", Value: a.baseURL},
+		},
+	}
+	folders := make(map[string]*postmanItem)
+	for _, e := range a.service.Endpoints {
+		r := &postmanRequest{
+			Method: strings.ToUpper(e.Method),
+			Header: []postmanKV{},
+			URL: postmanURL{
+				Raw:  "{{baseURL}}/v" + a.service.Version + e.Path,
+				Host: []string{"{{baseURL}}"},
+				Path: strings.Split("v"+a.service.Version+e.Path, "/"),
+			},
+		}
+		for _, p := range e.Params {
+			kv := postmanKV{Key: p.Name, Value: paramValue(p), Description: p.Desc}
+			if pathParam(e, p) {
+				r.URL.Variable = append(r.URL.Variable, kv)
+				continue
+			}
+			kv.Disabled = true
+			r.URL.Query = append(r.URL.Query, kv)
+		}
+		if e.Payload != "" {
+			r.Header = append(r.Header, postmanKV{Key: "Content-Type", Value: "application/json"})
+			r.Body = &postmanBody{
+				Mode: "raw",
+				Raw:  indentJSON(e.Payload, ""),
+				Options: map[string]interface{}{
+					"raw": map[string]string{"language": "json"},
+				},
+			}
+		}
+		item := &postmanItem{Name: strings.ToUpper(e.Method) + " " + e.Path, Request: r}
+		name := exportFolder(e)
+		if name == "" {
+			o.Item = append(o.Item, item)
+			continue
+		}
+		f, ok := folders[name]
+		if !ok {
+			f = &postmanItem{Name: name}
+			folders[name] = f
+			o.Item = append(o.Item, f)
+		}
+		f.Item = append(f.Item, item)
+	}
+	return o
+}
+
+type insomniaExport struct {
+	Type      string              `json:"_type"`
+	Format    int                 `json:"__export_format"`
+	Source    string              `json:"__export_source"`
+	Resources []*insomniaResource `json:"resources"`
+}
+
+type insomniaResource struct {
+	ID         string            `json:"_id"`
+	Type       string            `json:"_type"`
+	ParentID   *string           `json:"parentId"`
+	Name       string            `json:"name"`
+	Method     string            `json:"method,omitempty"`
+	URL        string            `json:"url,omitempty"`
+	Body       *insomniaBody     `json:"body,omitempty"`
+	Headers    []insomniaKV      `json:"headers,omitempty"`
+	Parameters []insomniaKV      `json:"parameters,omitempty"`
+	Data       map[string]string `json:"data,omitempty"`
+}
+
+type insomniaBody struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type insomniaKV struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+func (a *api) insomnia() *insomniaExport {
+	wrk := "wrk_qlfu"
+	o := &insomniaExport{
+		Type:   "export",
+		Format: 4,
+		Source: "qlfu",
+		Resources: []*insomniaResource{
+			{ID: wrk, Type: "workspace", Name: "qlfu"},
+			{
+				ID:       "env_qlfu",
+				Type:     "environment",
+				ParentID: &wrk,
+				Name:     "Base Environment",
+				Data:     map[string]string{"baseURL": a.baseURL},
+			},
+		},
+	}
+	folders := make(map[string]bool)
+	for k, e := range a.service.Endpoints {
+		parent := wrk
+		if name := exportFolder(e); name != "" {
+			parent = "fld_" + name
+			if !folders[name] {
+				folders[name] = true
+				p := wrk
+				o.Resources = append(o.Resources, &insomniaResource{
+					ID:       parent,
+					Type:     "request_group",
+					ParentID: &p,
+					Name:     name,
+				})
+			}
+		}
+		var path []param
+		r := &insomniaResource{
+			ID:       fmt.Sprintf("req_qlfu_%d", k),
+			Type:     "request",
+			ParentID: &parent,
+			Name:     strings.ToUpper(e.Method) + " " + e.Path,
+			Method:   strings.ToUpper(e.Method),
+		}
+		for _, p := range e.Params {
+			if pathParam(e, p) {
+				path = append(path, p)
+				continue
+			}
+			r.Parameters = append(r.Parameters, insomniaKV{
+				Name:        p.Name,
+				Value:       paramValue(p),
+				Description: p.Desc,
+				Disabled:    true,
+			})
+		}
+		r.URL = "{{ _.baseURL }}/v" + a.service.Version + replaceParams(e.Path, path)
+		if e.Payload != "" {
+			r.Headers = []insomniaKV{{Name: "Content-Type", Value: "application/json"}}
+			r.Body = &insomniaBody{MimeType: "application/json", Text: indentJSON(e.Payload, "")}
+		}
+		o.Resources = append(o.Resources, r)
+	}
+	return o
+}
+
+func (a *api) exportPostman(w http.ResponseWriter, r *http.Request) {
+	writeExport(w, "qlfu.postman_collection.json", a.postman())
+}
+
+func (a *api) exportInsomnia(w http.ResponseWriter, r *http.Request) {
+	writeExport(w, "qlfu.insomnia.json", a.insomnia())
+}
+
+func writeExport(w http.ResponseWriter, name string, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		jsonErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	_, _ = w.Write(b)
+}
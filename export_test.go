@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_export(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"user":{"username":"gernest"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/_export/postman", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	var p postmanCollection
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Info.Schema != postmanSchema {
+		t.Errorf("expected %s got %s", postmanSchema, p.Info.Schema)
+	}
+	if len(p.Variable) != 1 || p.Variable[0].Key != "baseURL" || p.Variable[0].Value != "http://localhost:8090" {
+		t.Errorf("expected a baseURL variable got %v", p.Variable)
+	}
+	var users *postmanItem
+	for _, v := range p.Item {
+		if v.Name == "users" {
+			users = v
+		}
+	}
+	if users == nil {
+		t.Fatalf("expected a users folder in %s", w.Body)
+	}
+	items := make(map[string]*postmanRequest)
+	for _, v := range users.Item {
+		items[v.Name] = v.Request
+	}
+	post := items["POST /users"]
+	if post == nil || post.Body == nil || !json.Valid([]byte(post.Body.Raw)) || !strings.Contains(post.Body.Raw, "username") {
+		t.Fatalf("expected a json body for POST /users got %+v", post)
+	}
+	get := items["GET /users/:id"]
+	if get == nil {
+		t.Fatal("expected GET /users/:id")
+	}
+	if get.URL.Raw != "{{baseURL}}/v1/users/:id" {
+		t.Errorf("expected {{baseURL}}/v1/users/:id got %s", get.URL.Raw)
+	}
+	if len(get.URL.Variable) != 1 || get.URL.Variable[0].Key != "id" || get.URL.Variable[0].Value != "1" {
+		t.Errorf("expected an id variable got %v", get.URL.Variable)
+	}
+	if list := items["GET /users"]; list == nil || len(list.URL.Query) == 0 || !list.URL.Query[0].Disabled {
+		t.Errorf("expected disabled query params for GET /users got %+v", list)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/_export/insomnia", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	var i insomniaExport
+	if err := json.Unmarshal(w.Body.Bytes(), &i); err != nil {
+		t.Fatal(err)
+	}
+	var env, folder, req bool
+	for _, v := range i.Resources {
+		switch {
+		case v.Type == "environment":
+			env = v.Data["baseURL"] == "http://localhost:8090"
+		case v.Type == "request_group" && v.ID == "fld_users":
+			folder = true
+		case v.Type == "request" && v.Name == "GET /users/:id":
+			req = v.URL == "{{ _.baseURL }}/v1/users/1" && *v.ParentID == "fld_users"
+		}
+	}
+	if !env || !folder || !req {
+		t.Errorf("expected environment, folder and request got %v %v %v in %s", env, folder, req, w.Body)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/cznic/ql"
+	"github.com/jinzhu/inflection"
+	"github.com/urfave/cli"
+)
+
+var goInitialisms = map[string]bool{
+	"api":  true,
+	"html": true,
+	"http": true,
+	"id":   true,
+	"ip":   true,
+	"json": true,
+	"sql":  true,
+	"uri":  true,
+	"url":  true,
+	"uuid": true,
+}
+
+func goName(src string) string {
+	var o string
+	for _, v := range strings.FieldsFunc(src, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if goInitialisms[strings.ToLower(v)] {
+			o += strings.ToUpper(v)
+			continue
+		}
+		o += strings.ToUpper(v[:1]) + v[1:]
+	}
+	if o == "" || unicode.IsDigit(rune(o[0])) {
+		o = "X" + o
+	}
+	return o
+}
+
+func modelTypeName(table string) string {
+	return goName(inflection.Singular(table))
+}
+
+func genCrud(ctx *cli.Context) (*crud, func(), error) {
+	var src []byte
+	var err error
+	if f := ctx.String("schema"); f != "" {
+		src, err = ioutil.ReadFile(f)
+	} else {
+		src, err = fetchSchema(ctx.String("baseurl"))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	l, err := compileDDL(string(src))
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := ql.OpenMem()
+	if err != nil {
+		return nil, nil, err
+	}
+	done := func() {
+		_ = db.Close()
+	}
+	err = runDDL(db, l)
+	if err != nil {
+		done()
+		return nil, nil, err
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		done()
+		return nil, nil, err
+	}
+	return c, done, nil
+}
+
+func fetchSchema(baseURL string) ([]byte, error) {
+	res, err := http.Get(strings.TrimSuffix(baseURL, "/") + "/schema")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching schema: %s", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+func genFlags(out string) []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "schema",
+			Usage: "ql file with the schema, defaults to the schema of the running server",
+		},
+		cli.StringFlag{
+			Name:   "baseurl",
+			Usage:  "base url of the running qlfu server",
+			Value:  "http://localhost:8090",
+			EnvVar: "QLFU_BASEURL",
+		},
+		cli.StringFlag{
+			Name:  "out",
+			Usage: "directory the generated files are written to",
+			Value: out,
+		},
+	}
+}
+
+var genPackageFlag = cli.StringFlag{
+	Name:  "package",
+	Usage: "name of the generated go package, defaults to the name of the out directory",
+}
+
+func genPackage(ctx *cli.Context) string {
+	if p := ctx.String("package"); p != "" {
+		return p
+	}
+	abs, err := filepath.Abs(ctx.String("out"))
+	if err != nil {
+		return "client"
+	}
+	p := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, filepath.Base(abs))
+	if p == "" || unicode.IsDigit(rune(p[0])) {
+		return "client"
+	}
+	return p
+}
+
+func writeGenerated(ctx *cli.Context, name string, b []byte) error {
+	out := ctx.String("out")
+	if out == "" {
+		return errors.New("--out is required")
+	}
+	err := os.MkdirAll(out, 0755)
+	if err != nil {
+		return err
+	}
+	f := filepath.Join(out, name)
+	err = ioutil.WriteFile(f, b, 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Println(f)
+	return nil
+}
+
+func genGoClientCommand(ctx *cli.Context) error {
+	c, done, err := genCrud(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	b, err := genGoClient(c, genPackage(ctx))
+	if err != nil {
+		return err
+	}
+	return writeGenerated(ctx, "client.go", b)
+}
+
+func genTSClientCommand(ctx *cli.Context) error {
+	c, done, err := genCrud(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	b, err := genTSClient(c)
+	if err != nil {
+		return err
+	}
+	return writeGenerated(ctx, "client.ts", b)
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cznic/ql"
+	"github.com/gernest/qlfu/graphql"
+	"github.com/jinzhu/inflection"
+)
+
+// gqlReserved are the type names the schema declares itself, models with one
+// of them get a Model suffix.
+var gqlReserved = map[string]bool{
+	"Query":    true,
+	"Mutation": true,
+	"Int":      true,
+	"Float":    true,
+	"String":   true,
+	"Boolean":  true,
+	"ID":       true,
+	"Time":     true,
+	"Duration": true,
+	"Blob":     true,
+	"BigInt":   true,
+	"BigRat":   true,
+}
+
+// gqlModelName gives the object type of table, its input type is the same
+// name with an Input suffix.
+func gqlModelName(s *graphql.Schema, table string) string {
+	n := modelTypeName(table)
+	for gqlReserved[n] || s.Lookup(n) != nil || s.Lookup(n+"Input") != nil {
+		n += "Model"
+	}
+	return n
+}
+
+func newGQLSchema() (*graphql.Schema, error) {
+	s := graphql.NewSchema()
+	for _, name := range []string{"Time", "Duration", "Blob", "BigInt", "BigRat"} {
+		err := s.Add(&graphql.Type{Kind: graphql.Scalar, Name: name, Desc: gqlScalarDesc[name]})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+var gqlScalarDesc = map[string]string{
+	"Time":     "RFC 3339 time",
+	"Duration": "nanoseconds, or a go duration string like 1h30m as input",
+	"Blob":     "base64 encoded bytes",
+	"BigInt":   "arbitrary precision integer",
+	"BigRat":   "arbitrary precision rational number like 1/3",
+}
+
+func gqlScalarName(typ ql.Type) string {
+	switch typ {
+	case ql.Bool:
+		return "Boolean"
+	case ql.Int8, ql.Int16, ql.Int32, ql.Int64,
+		ql.Uint8, ql.Uint16, ql.Uint32, ql.Uint64:
+		return "Int"
+	case ql.Float32, ql.Float64:
+		return "Float"
+	case ql.Time:
+		return "Time"
+	case ql.Duration:
+		return "Duration"
+	case ql.Blob:
+		return "Blob"
+	case ql.BigInt:
+		return "BigInt"
+	case ql.BigRat:
+		return "BigRat"
+	}
+	return "String"
+}
+
+func graphqlSchema(c *crud) (*graphql.Schema, error) {
+	s, err := newGQLSchema()
+	if err != nil {
+		return nil, err
+	}
+	s.Extensions = gqlExtensions
+	query := &graphql.Type{Kind: graphql.Object, Name: "Query"}
+	mutation := &graphql.Type{Kind: graphql.Object, Name: "Mutation"}
+	objects := make(map[string]*graphql.Type)
+	inputs := make(map[string]*graphql.Type)
+	for _, t := range c.models() {
+		name := gqlModelName(s, t.name)
+		objects[t.name] = &graphql.Type{Kind: graphql.Object, Name: name, Desc: "A record of the " + t.name + " table."}
+		inputs[t.name] = &graphql.Type{Kind: graphql.InputObject, Name: name + "Input"}
+		if err := s.Add(objects[t.name], inputs[t.name]); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range c.models() {
+		t := t
+		obj, in := objects[t.name], inputs[t.name]
+		for _, col := range t.columns {
+			col := col
+			typ := s.Lookup(gqlScalarName(col.typ))
+			f := &graphql.Field{
+				Name: col.name,
+				Type: typ,
+				Resolve: func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+					return src.(modelProps)[col.name], nil
+				},
+			}
+			if col.notNull || col.name == "id" {
+				f.Type = graphql.NonNull(typ)
+			}
+			obj.Fields = append(obj.Fields, f)
+			if col.name != "id" && col.name != deletedAt {
+				in.Inputs = append(in.Inputs, &graphql.Input{Name: col.name, Type: typ})
+			}
+		}
+		if r := t.hasOne; r != nil && hasIDColumn(c, r.destTable) {
+			name := inflection.Singular(r.destTable)
+			if obj.Field(name) == nil {
+				obj.Fields = append(obj.Fields, &graphql.Field{
+					Name: name,
+					Type: objects[r.destTable],
+					Resolve: func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+						v := src.(modelProps)[r.srcCol]
+						if v == nil {
+							return nil, nil
+						}
+						l, err := c.list(r.destTable, &listQuery{filters: []*field{{Name: "id", value: v}}})
+						if err != nil || len(l) == 0 {
+							return nil, err
+						}
+						return l[0], nil
+					},
+				})
+				in.Inputs = append(in.Inputs, &graphql.Input{Name: name, Type: inputs[r.destTable]})
+			}
+		}
+		for _, r := range c.hasMany(t) {
+			r := r
+			if obj.Field(r.table.name) != nil || !hasIDColumn(c, t.name) {
+				continue
+			}
+			obj.Fields = append(obj.Fields, &graphql.Field{
+				Name: r.table.name,
+				Desc: r.table.name + " with " + r.fk + " pointing at this " + inflection.Singular(t.name),
+				Args: gqlListArgs(s, r.table),
+				Type: graphql.NonNull(graphql.ListOf(graphql.NonNull(objects[r.table.name]))),
+				Resolve: func(src interface{}, args map[string]interface{}) (interface{}, error) {
+					args[r.fk] = src.(modelProps)["id"]
+					return gqlRecords(c, r.table, args)
+				},
+			})
+		}
+		query.Fields = append(query.Fields, &graphql.Field{
+			Name: t.name,
+			Desc: "list " + t.name + ", the arguments filter by column value and page through the results",
+			Args: gqlListArgs(s, t),
+			Type: graphql.NonNull(graphql.ListOf(graphql.NonNull(obj))),
+			Resolve: func(_ interface{}, args map[string]interface{}) (interface{}, error) {
+				return gqlRecords(c, t, args)
+			},
+		})
+		mutation.Fields = append(mutation.Fields, &graphql.Field{
+			Name: "create" + obj.Name,
+			Args: []*graphql.Input{{Name: "input", Type: graphql.NonNull(in)}},
+			Type: obj,
+			Resolve: func(_ interface{}, args map[string]interface{}) (interface{}, error) {
+				props := gqlProps(args["input"])
+				if err := c.validate(t.name, props, true); err != nil {
+					return nil, err
+				}
+				return c.create(t.name, props)
+			},
+		})
+		if !hasIDColumn(c, t.name) {
+			continue
+		}
+		idArg := []*graphql.Input{{Name: "id", Type: graphql.NonNull(s.Lookup("Int"))}}
+		if query.Field(inflection.Singular(t.name)) == nil {
+			query.Fields = append(query.Fields, &graphql.Field{
+				Name: inflection.Singular(t.name),
+				Desc: "the " + inflection.Singular(t.name) + " with the given id",
+				Args: append(idArg[:1:1], gqlScopeArgs(s, t)...),
+				Type: obj,
+				Resolve: func(_ interface{}, args map[string]interface{}) (interface{}, error) {
+					id, err := gqlInt(args["id"])
+					if err != nil {
+						return nil, err
+					}
+					scope := scopeLive
+					if args[controlName(t, "with_deleted")] == true {
+						scope = scopeAll
+					}
+					l, err := c.getByID(t.name, id, scope)
+					if err != nil || len(l) == 0 {
+						return nil, err
+					}
+					return l[0], nil
+				},
+			})
+		}
+		mutation.Fields = append(mutation.Fields, &graphql.Field{
+			Name: "update" + obj.Name,
+			Args: append(idArg, &graphql.Input{Name: "input", Type: graphql.NonNull(in)}),
+			Type: obj,
+			Resolve: func(_ interface{}, args map[string]interface{}) (interface{}, error) {
+				id, err := gqlInt(args["id"])
+				if err != nil {
+					return nil, err
+				}
+				props := gqlProps(args["input"])
+				if err := c.validate(t.name, props, true); err != nil {
+					return nil, err
+				}
+				return c.updateByID(t.name, id, props, "")
+			},
+		})
+		mutation.Fields = append(mutation.Fields, &graphql.Field{
+			Name: "delete" + obj.Name,
+			Args: idArg,
+			Type: s.Lookup("Boolean"),
+			Resolve: func(_ interface{}, args map[string]interface{}) (interface{}, error) {
+				id, err := gqlInt(args["id"])
+				if err != nil {
+					return nil, err
+				}
+				if err := c.deleteByID(t.name, id, ""); err != nil {
+					return nil, err
+				}
+				return true, nil
+			},
+		})
+	}
+	if len(query.Fields) == 0 {
+		query.Fields = append(query.Fields, &graphql.Field{
+			Name: "version",
+			Desc: "the version of the api, there are no models yet",
+			Type: graphql.NonNull(s.Lookup("String")),
+			Resolve: func(interface{}, map[string]interface{}) (interface{}, error) {
+				return activeVersion, nil
+			},
+		})
+	}
+	s.Query = query
+	if len(mutation.Fields) > 0 {
+		s.Mutation = mutation
+		return s, s.Add(query, mutation)
+	}
+	return s, s.Add(query)
+}
+
+func gqlExtensions(err error) map[string]interface{} {
+	switch v := err.(type) {
+	case *validationError:
+		return map[string]interface{}{"fields": v.fields}
+	case *hookError:
+		return map[string]interface{}{"status": v.code}
+	}
+	return nil
+}
+
+func hasIDColumn(c *crud, model string) bool {
+	if t, ok := c.schema.tables[model]; ok {
+		_, ok = t.colID("id")
+		return ok
+	}
+	return false
+}
+
+func gqlListArgs(s *graphql.Schema, t *table) []*graphql.Input {
+	var o []*graphql.Input
+	for _, col := range t.columns {
+		o = append(o, &graphql.Input{
+			Name: col.name,
+			Desc: "only " + t.name + " with the given " + col.name,
+			Type: s.Lookup(gqlScalarName(col.typ)),
+		})
+	}
+	o = append(o, gqlScopeArgs(s, t)...)
+	return append(o,
+		&graphql.Input{Name: controlName(t, "limit"), Type: s.Lookup("Int")},
+		&graphql.Input{Name: controlName(t, "offset"), Type: s.Lookup("Int")},
+	)
+}
+
+func gqlScopeArgs(s *graphql.Schema, t *table) []*graphql.Input {
+	if !t.softDelete {
+		return nil
+	}
+	return []*graphql.Input{{Name: controlName(t, "with_deleted"), Type: s.Lookup("Boolean"), Default: "false"}}
+}
+
+func gqlRecords(c *crud, t *table, args map[string]interface{}) (interface{}, error) {
+	q := &listQuery{}
+	verr := &validationError{}
+	var names []string
+	for k := range args {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := args[k]
+		switch k {
+		case controlName(t, "with_deleted"):
+			if v == true {
+				q.scope = scopeAll
+			}
+		case controlName(t, "limit"), controlName(t, "offset"):
+			if v == nil {
+				continue
+			}
+			n, err := gqlInt(v)
+			if err != nil || n < 0 {
+				verr.add(k, "expected a positive integer")
+				continue
+			}
+			if k == controlName(t, "limit") {
+				q.limit = int(n)
+			} else {
+				q.offset = int(n)
+			}
+		default:
+			idx, ok := t.colID(k)
+			if !ok || v == nil {
+				continue
+			}
+			cv, err := coerce(t.columns[idx].typ, v)
+			if err != nil {
+				verr.add(k, err.Error())
+				continue
+			}
+			q.filters = append(q.filters, &field{Name: k, value: cv})
+		}
+	}
+	if verr.failed() {
+		return nil, verr
+	}
+	l, err := c.list(t.name, q)
+	if err != nil {
+		return nil, err
+	}
+	o := make([]interface{}, 0, len(l))
+	for _, v := range l {
+		o = append(o, v)
+	}
+	return o, nil
+}
+
+func gqlInt(v interface{}) (int64, error) {
+	n, err := toInt(ql.Int64, v)
+	if err != nil {
+		return 0, err
+	}
+	return n.(int64), nil
+}
+
+func gqlProps(v interface{}) modelProps {
+	m, _ := v.(map[string]interface{})
+	if m == nil {
+		return make(modelProps)
+	}
+	return modelProps(m)
+}
+
+func (a *api) graphql(w http.ResponseWriter, r *http.Request) {
+	req := &graphql.Request{}
+	readOnly := r.Method == http.MethodGet
+	if readOnly {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			dec := json.NewDecoder(strings.NewReader(v))
+			dec.UseNumber()
+			if err := dec.Decode(&req.Variables); err != nil {
+				gqlErr(w, err)
+				return
+			}
+		}
+	} else {
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(req); err != nil {
+			gqlErr(w, err)
+			return
+		}
+	}
+	if req.Query == "" {
+		gqlErr(w, errors.New("missing query"))
+		return
+	}
+	res, err := a.gql.Execute(req, readOnly)
+	if err != nil {
+		gqlErr(w, err)
+		return
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		gqlErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+func gqlErr(w http.ResponseWriter, err error) {
+	b, _ := json.Marshal(&graphql.Response{Errors: []*graphql.Error{{Message: err.Error()}}})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,318 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// errNull is returned while completing a value that had to be null, the error
+// itself has already been reported.
+var errNull = errors.New("null")
+
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// result keeps the fields of an object in the order they were selected.
+type result struct {
+	keys []string
+	vals map[string]interface{}
+}
+
+func (r *result) set(k string, v interface{}) {
+	if _, ok := r.vals[k]; !ok {
+		r.keys = append(r.keys, k)
+	}
+	r.vals[k] = v
+}
+
+func (r *result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for k, key := range r.keys {
+		if k > 0 {
+			buf.WriteByte(',')
+		}
+		b, _ := json.Marshal(key)
+		buf.Write(b)
+		buf.WriteByte(':')
+		b, err := json.Marshal(r.vals[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type executor struct {
+	s    *Schema
+	doc  *document
+	vars map[string]interface{}
+	errs []*Error
+}
+
+// Execute runs the operation of req, mutations are refused when readOnly is
+// set. The error is for requests that could not run at all.
+func (s *Schema) Execute(req *Request, readOnly bool) (*Response, error) {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return nil, err
+	}
+	var op *operation
+	for _, v := range doc.ops {
+		switch {
+		case req.OperationName == "" && op != nil:
+			return nil, errors.New("operationName is required for documents with more than one operation")
+		case req.OperationName == "" || v.name == req.OperationName:
+			op = v
+		}
+	}
+	if op == nil {
+		return nil, fmt.Errorf("unknown operation %s", req.OperationName)
+	}
+	root := s.Query
+	switch op.kind {
+	case "mutation":
+		if readOnly {
+			return nil, errors.New("mutations are only allowed on POST")
+		}
+		if s.Mutation == nil {
+			return nil, errors.New("the schema has no mutations")
+		}
+		root = s.Mutation
+	case "subscription":
+		return nil, errors.New("subscriptions are not supported")
+	}
+	ex := &executor{s: s, doc: doc, vars: make(map[string]interface{})}
+	for _, v := range op.vars {
+		val, ok := req.Variables[v.name]
+		switch {
+		case ok:
+			ex.vars[v.name] = val
+		case v.def != nil:
+			ex.vars[v.name] = v.def.resolve(nil)
+		}
+		if ex.vars[v.name] == nil && v.typ.nonNull {
+			return nil, fmt.Errorf("variable $%s of type %s is required", v.name, v.typ)
+		}
+	}
+	data, err := ex.selection(root, nil, op.sels, nil)
+	res := &Response{Errors: ex.errs}
+	if err == nil {
+		res.Data = data
+	}
+	return res, nil
+}
+
+func (ex *executor) report(err error, path []interface{}) {
+	e := &Error{Message: err.Error(), Path: path}
+	if ex.s.Extensions != nil {
+		e.Extensions = ex.s.Extensions(err)
+	}
+	ex.errs = append(ex.errs, e)
+}
+
+func (ex *executor) include(dirs []*directive) bool {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			continue
+		}
+		var cond bool
+		for _, a := range d.args {
+			if a.name == "if" {
+				cond = a.value.resolve(ex.vars) == true
+			}
+		}
+		if (d.name == "skip") == cond {
+			return false
+		}
+	}
+	return true
+}
+
+// collect groups the fields selected on typ by response key, following the
+// fragments that apply to typ.
+func (ex *executor) collect(typ *Type, sels []*selection, keys *[]string, fields map[string][]*selection, seen map[string]bool) {
+	for _, v := range sels {
+		if !ex.include(v.dirs) {
+			continue
+		}
+		switch {
+		case v.spread != "":
+			f, ok := ex.doc.frags[v.spread]
+			if !ok || seen[v.spread] || f.on != typ.Name || !ex.include(f.dirs) {
+				continue
+			}
+			seen[v.spread] = true
+			ex.collect(typ, f.sels, keys, fields, seen)
+		case v.inline:
+			if v.on == "" || v.on == typ.Name {
+				ex.collect(typ, v.sels, keys, fields, seen)
+			}
+		default:
+			k := v.key()
+			if _, ok := fields[k]; !ok {
+				*keys = append(*keys, k)
+			}
+			fields[k] = append(fields[k], v)
+		}
+	}
+}
+
+func (ex *executor) selection(typ *Type, src interface{}, sels []*selection, path []interface{}) (*result, error) {
+	var keys []string
+	fields := make(map[string][]*selection)
+	ex.collect(typ, sels, &keys, fields, make(map[string]bool))
+	o := &result{vals: make(map[string]interface{})}
+	for _, k := range keys {
+		sel := fields[k][0]
+		p := append(append([]interface{}{}, path...), k)
+		if sel.name == "__typename" {
+			o.set(k, typ.Name)
+			continue
+		}
+		f := typ.Field(sel.name)
+		if f == nil && typ == ex.s.Query {
+			f = ex.s.meta[sel.name]
+		}
+		if f == nil {
+			ex.report(fmt.Errorf("unknown field %s on type %s", sel.name, typ.Name), p)
+			o.set(k, nil)
+			continue
+		}
+		var sub []*selection
+		for _, v := range fields[k] {
+			sub = append(sub, v.sels...)
+		}
+		v, err := ex.field(f, src, sel, sub, p)
+		if err != nil {
+			if f.Type.Kind == NonNullKind {
+				return nil, errNull
+			}
+			v = nil
+		}
+		o.set(k, v)
+	}
+	return o, nil
+}
+
+func (ex *executor) field(f *Field, src interface{}, sel *selection, sub []*selection, path []interface{}) (interface{}, error) {
+	args, err := ex.args(f, sel)
+	if err != nil {
+		ex.report(err, path)
+		return nil, errNull
+	}
+	v, err := f.Resolve(src, args)
+	if err != nil {
+		ex.report(err, path)
+		return nil, errNull
+	}
+	return ex.complete(f.Type, sub, v, path)
+}
+
+func (ex *executor) args(f *Field, sel *selection) (map[string]interface{}, error) {
+	o := make(map[string]interface{})
+	given := make(map[string]*value)
+	for _, a := range sel.args {
+		given[a.name] = a.value
+	}
+	for _, a := range f.Args {
+		v, ok := given[a.Name]
+		delete(given, a.Name)
+		switch {
+		case ok && !v.missing(ex.vars):
+			o[a.Name] = v.resolve(ex.vars)
+		case a.Default != "":
+			d, err := parseValue(a.Default)
+			if err != nil {
+				return nil, err
+			}
+			o[a.Name] = d.resolve(nil)
+		}
+		if o[a.Name] == nil && a.Type.Kind == NonNullKind {
+			return nil, fmt.Errorf("argument %s of type %s is required", a.Name, a.Type)
+		}
+	}
+	for k := range given {
+		return nil, fmt.Errorf("unknown argument %s on field %s", k, f.Name)
+	}
+	return o, nil
+}
+
+func parseValue(src string) (*value, error) {
+	p := &parser{l: &lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return p.value(true)
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch r := reflect.ValueOf(v); r.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return r.IsNil()
+	}
+	return false
+}
+
+func (ex *executor) complete(typ *Type, sels []*selection, v interface{}, path []interface{}) (interface{}, error) {
+	if typ.Kind == NonNullKind {
+		if isNil(v) {
+			ex.report(fmt.Errorf("cannot return null for non-nullable type %s", typ), path)
+			return nil, errNull
+		}
+		return ex.complete(typ.OfType, sels, v, path)
+	}
+	if isNil(v) {
+		return nil, nil
+	}
+	switch typ.Kind {
+	case List:
+		r := reflect.ValueOf(v)
+		if r.Kind() != reflect.Slice {
+			ex.report(fmt.Errorf("expected a list for %s", typ), path)
+			return nil, errNull
+		}
+		o := make([]interface{}, 0, r.Len())
+		for i := 0; i < r.Len(); i++ {
+			x, err := ex.complete(typ.OfType, sels, r.Index(i).Interface(), append(append([]interface{}{}, path...), i))
+			if err != nil && typ.OfType.Kind == NonNullKind {
+				return nil, err
+			}
+			o = append(o, x)
+		}
+		return o, nil
+	case Object:
+		if len(sels) == 0 {
+			ex.report(fmt.Errorf("field of type %s needs a selection of subfields", typ), path)
+			return nil, errNull
+		}
+		o, err := ex.selection(typ, v, sels, path)
+		if err != nil {
+			return nil, err
+		}
+		return o, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,204 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testSchema(t *testing.T) *Schema {
+	s := NewSchema()
+	str := s.Lookup("String")
+	user := &Type{Kind: Object, Name: "User", Fields: []*Field{
+		{
+			Name: "name",
+			Type: NonNull(str),
+			Resolve: func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+				return src.(map[string]interface{})["name"], nil
+			},
+		},
+	}}
+	users := []interface{}{
+		map[string]interface{}{"name": "a"},
+		map[string]interface{}{},
+	}
+	query := &Type{Kind: Object, Name: "Query", Fields: []*Field{
+		{
+			Name: "greet",
+			Args: []*Input{{Name: "name", Type: NonNull(str)}, {Name: "greeting", Type: str, Default: `"hello"`}},
+			Type: str,
+			Resolve: func(_ interface{}, args map[string]interface{}) (interface{}, error) {
+				return args["greeting"].(string) + " " + args["name"].(string), nil
+			},
+		},
+		{
+			Name: "users",
+			Type: ListOf(NonNull(user)),
+			Resolve: func(interface{}, map[string]interface{}) (interface{}, error) {
+				return users, nil
+			},
+		},
+		{
+			Name: "fail",
+			Type: str,
+			Resolve: func(interface{}, map[string]interface{}) (interface{}, error) {
+				return nil, errors.New("failed")
+			},
+		},
+	}}
+	mutation := &Type{Kind: Object, Name: "Mutation", Fields: []*Field{
+		{
+			Name: "ping",
+			Type: str,
+			Resolve: func(interface{}, map[string]interface{}) (interface{}, error) {
+				return "pong", nil
+			},
+		},
+	}}
+	if err := s.Add(user, query, mutation); err != nil {
+		t.Fatal(err)
+	}
+	s.Query, s.Mutation = query, mutation
+	s.Extensions = func(err error) map[string]interface{} {
+		return map[string]interface{}{"kind": "test"}
+	}
+	return s
+}
+
+func TestSchema_Add(t *testing.T) {
+	s := NewSchema()
+	if err := s.Add(&Type{Kind: Object, Name: "String"}); err == nil {
+		t.Error("expected an error redefining String")
+	}
+	if err := s.Add(&Type{Kind: Object, Name: "A"}, &Type{Kind: Object, Name: "__Type"}); err == nil {
+		t.Error("expected an error redefining __Type")
+	}
+	if s.Lookup("A") != nil {
+		t.Error("expected a failed Add to add nothing")
+	}
+}
+
+func TestSchema_Execute(t *testing.T) {
+	s := testSchema(t)
+	sample := []struct {
+		query, op string
+		vars      map[string]interface{}
+		expect    string
+	}{
+		{query: `{ greet(name: "x") }`, expect: `{"data":{"greet":"hello x"}}`},
+		{
+			query:  `query ($n: String!) { a: greet(name: $n, greeting: "hi") b: greet(name: $n) }`,
+			vars:   map[string]interface{}{"n": "y"},
+			expect: `{"data":{"a":"hi y","b":"hello y"}}`,
+		},
+		{
+			query:  `{ greet fail }`,
+			expect: `{"data":{"greet":null,"fail":null},"errors":[{"message":"argument name of type String! is required","path":["greet"],"extensions":{"kind":"test"}},{"message":"failed","path":["fail"],"extensions":{"kind":"test"}}]}`,
+		},
+		{
+			query:  `{ greet(name: "x", other: 1) missing }`,
+			expect: `{"data":{"greet":null,"missing":null},"errors":[{"message":"unknown argument other on field greet","path":["greet"],"extensions":{"kind":"test"}},{"message":"unknown field missing on type Query","path":["missing"],"extensions":{"kind":"test"}}]}`,
+		},
+		{
+			query:  `{ users { name } }`,
+			expect: `{"data":{"users":null},"errors":[{"message":"cannot return null for non-nullable type String!","path":["users",1,"name"],"extensions":{"kind":"test"}}]}`,
+		},
+		{
+			query:  `{ users }`,
+			expect: `{"data":{"users":null},"errors":[{"message":"field of type User needs a selection of subfields","path":["users",0],"extensions":{"kind":"test"}}]}`,
+		},
+		{query: `query a { greet(name: "a") } query b { greet(name: "b") }`, op: "b", expect: `{"data":{"greet":"hello b"}}`},
+		{query: `mutation { ping __typename }`, expect: `{"data":{"ping":"pong","__typename":"Mutation"}}`},
+	}
+	for _, v := range sample {
+		res, err := s.Execute(&Request{Query: v.query, OperationName: v.op, Variables: v.vars}, false)
+		if err != nil {
+			t.Errorf("%s: %v", v.query, err)
+			continue
+		}
+		b, _ := json.Marshal(res)
+		if string(b) != v.expect {
+			t.Errorf("%s\nexpected %s\ngot      %s", v.query, v.expect, b)
+		}
+	}
+
+	for _, v := range []struct {
+		query, op string
+		readOnly  bool
+	}{
+		{query: `{ greet(`},
+		{query: `query a { greet(name: "a") } query b { greet(name: "b") }`},
+		{query: `{ greet(name: "a") }`, op: "c"},
+		{query: `query ($n: String!) { greet(name: $n) }`},
+		{query: `mutation { ping }`, readOnly: true},
+		{query: `subscription { ping }`},
+	} {
+		if _, err := s.Execute(&Request{Query: v.query, OperationName: v.op}, v.readOnly); err == nil {
+			t.Errorf("expected %s to fail", v.query)
+		}
+	}
+}
+
+func TestSchema_introspection(t *testing.T) {
+	s := testSchema(t)
+	res, err := s.Execute(&Request{Query: introspectionQuery}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(res)
+	if len(res.Errors) > 0 || !strings.Contains(string(b), `"name":"User"`) {
+		t.Errorf("unexpected introspection result %s", b)
+	}
+	res, err = s.Execute(&Request{Query: `{ __type(name: "Query") { fields { name args { name defaultValue } } } }`}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = json.Marshal(res)
+	expect := `{"data":{"__type":{"fields":[{"name":"greet","args":[{"name":"name","defaultValue":null},{"name":"greeting","defaultValue":"\"hello\""}]},{"name":"users","args":[]},{"name":"fail","args":[]}]}}}`
+	if string(b) != expect {
+		t.Errorf("expected %s got %s", expect, b)
+	}
+}
+
+const introspectionQuery = `
+query IntrospectionQuery {
+	__schema {
+		queryType { name }
+		mutationType { name }
+		subscriptionType { name }
+		types { ...FullType }
+		directives { name description locations args { ...InputValue } }
+	}
+}
+
+fragment FullType on __Type {
+	kind
+	name
+	description
+	fields(includeDeprecated: true) {
+		name
+		description
+		args { ...InputValue }
+		type { ...TypeRef }
+		isDeprecated
+		deprecationReason
+	}
+	inputFields { ...InputValue }
+	interfaces { ...TypeRef }
+	enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+	possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+	name
+	description
+	type { ...TypeRef }
+	defaultValue
+}
+
+fragment TypeRef on __Type {
+	kind
+	name
+	ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } }
+}`
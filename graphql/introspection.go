@@ -0,0 +1,214 @@
+package graphql
+
+func optional(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+func introspectionTypes(s *Schema) {
+	str, boolean := s.types["String"], s.types["Boolean"]
+	schema := s.add(&Type{Kind: Object, Name: "__Schema"})
+	typ := s.add(&Type{Kind: Object, Name: "__Type"})
+	fld := s.add(&Type{Kind: Object, Name: "__Field"})
+	input := s.add(&Type{Kind: Object, Name: "__InputValue"})
+	enum := s.add(&Type{Kind: Object, Name: "__EnumValue"})
+	directive := s.add(&Type{Kind: Object, Name: "__Directive"})
+	kind := s.add(&Type{Kind: Enum, Name: "__TypeKind", Enums: []string{
+		Scalar, Object, "INTERFACE", "UNION", Enum, InputObject, List, NonNullKind,
+	}})
+	location := s.add(&Type{Kind: Enum, Name: "__DirectiveLocation", Enums: []string{
+		"QUERY", "MUTATION", "SUBSCRIPTION", "FIELD", "FRAGMENT_DEFINITION", "FRAGMENT_SPREAD",
+		"INLINE_FRAGMENT", "VARIABLE_DEFINITION", "SCHEMA", "SCALAR", "OBJECT", "FIELD_DEFINITION",
+		"ARGUMENT_DEFINITION", "INTERFACE", "UNION", "ENUM", "ENUM_VALUE", "INPUT_OBJECT",
+		"INPUT_FIELD_DEFINITION",
+	}})
+	deprecated := []*Input{{Name: "includeDeprecated", Type: boolean, Default: "false"}}
+	notDeprecated := func(interface{}, map[string]interface{}) (interface{}, error) {
+		return false, nil
+	}
+	none := func(interface{}, map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	field := func(name string, t *Type, fn Resolver) *Field {
+		return &Field{Name: name, Type: t, Resolve: fn}
+	}
+
+	schema.Fields = []*Field{
+		field("description", str, none),
+		field("types", NonNull(ListOf(NonNull(typ))), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			var o []*Type
+			for _, k := range s.typeNames() {
+				o = append(o, s.types[k])
+			}
+			return o, nil
+		}),
+		field("queryType", NonNull(typ), func(interface{}, map[string]interface{}) (interface{}, error) {
+			return s.Query, nil
+		}),
+		field("mutationType", typ, func(interface{}, map[string]interface{}) (interface{}, error) {
+			return s.Mutation, nil
+		}),
+		field("subscriptionType", typ, none),
+		field("directives", NonNull(ListOf(NonNull(directive))), func(interface{}, map[string]interface{}) (interface{}, error) {
+			return s.directives, nil
+		}),
+	}
+
+	typ.Fields = []*Field{
+		field("kind", NonNull(kind), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src.(*Type).Kind, nil
+		}),
+		field("name", str, func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return optional(src.(*Type).Name), nil
+		}),
+		field("description", str, func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return optional(src.(*Type).Desc), nil
+		}),
+		field("specifiedByURL", str, none),
+		{
+			Name: "fields",
+			Args: deprecated,
+			Type: ListOf(NonNull(fld)),
+			Resolve: func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+				if t := src.(*Type); t.Kind == Object {
+					return t.Fields, nil
+				}
+				return nil, nil
+			},
+		},
+		field("interfaces", ListOf(NonNull(typ)), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			if src.(*Type).Kind == Object {
+				return []*Type{}, nil
+			}
+			return nil, nil
+		}),
+		field("possibleTypes", ListOf(NonNull(typ)), none),
+		{
+			Name: "enumValues",
+			Args: deprecated,
+			Type: ListOf(NonNull(enum)),
+			Resolve: func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+				if t := src.(*Type); t.Kind == Enum {
+					return t.Enums, nil
+				}
+				return nil, nil
+			},
+		},
+		{
+			Name: "inputFields",
+			Args: deprecated,
+			Type: ListOf(NonNull(input)),
+			Resolve: func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+				if t := src.(*Type); t.Kind == InputObject {
+					return t.Inputs, nil
+				}
+				return nil, nil
+			},
+		},
+		field("ofType", typ, func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src.(*Type).OfType, nil
+		}),
+		field("isOneOf", boolean, func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			if src.(*Type).Kind == InputObject {
+				return false, nil
+			}
+			return nil, nil
+		}),
+	}
+
+	fld.Fields = []*Field{
+		field("name", NonNull(str), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src.(*Field).Name, nil
+		}),
+		field("description", str, func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return optional(src.(*Field).Desc), nil
+		}),
+		{
+			Name: "args",
+			Args: deprecated,
+			Type: NonNull(ListOf(NonNull(input))),
+			Resolve: func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+				if a := src.(*Field).Args; a != nil {
+					return a, nil
+				}
+				return []*Input{}, nil
+			},
+		},
+		field("type", NonNull(typ), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src.(*Field).Type, nil
+		}),
+		field("isDeprecated", NonNull(boolean), notDeprecated),
+		field("deprecationReason", str, none),
+	}
+
+	input.Fields = []*Field{
+		field("name", NonNull(str), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src.(*Input).Name, nil
+		}),
+		field("description", str, func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return optional(src.(*Input).Desc), nil
+		}),
+		field("type", NonNull(typ), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src.(*Input).Type, nil
+		}),
+		field("defaultValue", str, func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return optional(src.(*Input).Default), nil
+		}),
+		field("isDeprecated", NonNull(boolean), notDeprecated),
+		field("deprecationReason", str, none),
+	}
+
+	enum.Fields = []*Field{
+		field("name", NonNull(str), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src, nil
+		}),
+		field("description", str, none),
+		field("isDeprecated", NonNull(boolean), notDeprecated),
+		field("deprecationReason", str, none),
+	}
+
+	directive.Fields = []*Field{
+		field("name", NonNull(str), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src.(*directiveDef).name, nil
+		}),
+		field("description", str, func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return optional(src.(*directiveDef).desc), nil
+		}),
+		field("locations", NonNull(ListOf(NonNull(location))), func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+			return src.(*directiveDef).locations, nil
+		}),
+		{
+			Name: "args",
+			Args: deprecated,
+			Type: NonNull(ListOf(NonNull(input))),
+			Resolve: func(src interface{}, _ map[string]interface{}) (interface{}, error) {
+				return src.(*directiveDef).args, nil
+			},
+		},
+		field("isRepeatable", NonNull(boolean), notDeprecated),
+	}
+
+	cond := []*Input{{Name: "if", Type: NonNull(boolean)}}
+	fieldLocations := []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"}
+	s.directives = []*directiveDef{
+		{name: "include", desc: "Include the field only when if is true.", locations: fieldLocations, args: cond},
+		{name: "skip", desc: "Skip the field when if is true.", locations: fieldLocations, args: cond},
+	}
+
+	s.meta = map[string]*Field{
+		"__schema": field("__schema", NonNull(schema), func(interface{}, map[string]interface{}) (interface{}, error) {
+			return s, nil
+		}),
+		"__type": {
+			Name: "__type",
+			Args: []*Input{{Name: "name", Type: NonNull(str)}},
+			Type: typ,
+			Resolve: func(_ interface{}, args map[string]interface{}) (interface{}, error) {
+				name, _ := args["name"].(string)
+				return s.types[name], nil
+			},
+		},
+	}
+}
@@ -0,0 +1,761 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind int
+	val  string
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	line, col := 1, 1
+	for _, r := range l.src[:pos] {
+		if r == '\n' {
+			line++
+			col = 1
+			continue
+		}
+		col++
+	}
+	return fmt.Errorf("syntax error at %d:%d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) skip() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skip()
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokPunct, val: "...", pos: start}, nil
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, val: string(c), pos: start}, nil
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokName, val: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		return l.blockString()
+	case c == '"':
+		return l.string()
+	}
+	return token{}, l.errorf(start, "unexpected character %q", c)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) digits() int {
+	n := 0
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+		n++
+	}
+	return n
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := l.pos
+	if n := l.digits(); n == 0 || n > 1 && l.src[digits] == '0' {
+		return token{}, l.errorf(start, "invalid number")
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokFloat
+		l.pos++
+		if l.digits() == 0 {
+			return token{}, l.errorf(start, "invalid number")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if l.digits() == 0 {
+			return token{}, l.errorf(start, "invalid number")
+		}
+	}
+	return token{kind: kind, val: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return token{kind: tokString, val: b.String(), pos: start}, nil
+		case '\n', '\r':
+			return token{}, l.errorf(start, "unterminated string")
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, l.errorf(start, "unterminated string")
+			}
+			e := l.src[l.pos+1]
+			l.pos += 2
+			switch e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return token{}, l.errorf(start, "invalid unicode escape")
+				}
+				n, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return token{}, l.errorf(start, "invalid unicode escape")
+				}
+				b.WriteRune(rune(n))
+				l.pos += 4
+			default:
+				return token{}, l.errorf(start, "invalid escape \\%c", e)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			b.WriteRune(r)
+			l.pos += size
+		}
+	}
+	return token{}, l.errorf(start, "unterminated string")
+}
+
+func (l *lexer) blockString() (token, error) {
+	start := l.pos
+	l.pos += 3
+	end := strings.Index(l.src[l.pos:], `"""`)
+	for end > 0 && l.src[l.pos+end-1] == '\\' {
+		next := strings.Index(l.src[l.pos+end+3:], `"""`)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += 3 + next
+	}
+	if end < 0 {
+		return token{}, l.errorf(start, "unterminated string")
+	}
+	raw := strings.Replace(l.src[l.pos:l.pos+end], `\"""`, `"""`, -1)
+	l.pos += end + 3
+	return token{kind: tokString, val: blockStringValue(raw), pos: start}, nil
+}
+
+// blockStringValue strips the common indentation and the blank first and last
+// lines of a block string.
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.Replace(raw, "\r\n", "\n", -1), "\n")
+	indent := -1
+	for _, v := range lines[1:] {
+		n := len(v) - len(strings.TrimLeft(v, " \t"))
+		if n < len(v) && (indent < 0 || n < indent) {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for k := 1; k < len(lines); k++ {
+			if len(lines[k]) >= indent {
+				lines[k] = lines[k][indent:]
+			} else {
+				lines[k] = strings.TrimLeft(lines[k], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+type document struct {
+	ops   []*operation
+	frags map[string]*fragment
+}
+
+type operation struct {
+	kind string
+	name string
+	vars []*varDef
+	dirs []*directive
+	sels []*selection
+}
+
+type varDef struct {
+	name string
+	typ  *typeRef
+	def  *value
+}
+
+type typeRef struct {
+	name    string
+	list    *typeRef
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.list != nil {
+		s = "[" + t.list.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+type fragment struct {
+	name string
+	on   string
+	dirs []*directive
+	sels []*selection
+}
+
+// selection is a field, a fragment spread when spread is set or an inline
+// fragment when inline is set.
+type selection struct {
+	alias  string
+	name   string
+	args   []*argument
+	dirs   []*directive
+	sels   []*selection
+	spread string
+	inline bool
+	on     string
+}
+
+func (s *selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type argument struct {
+	name  string
+	value *value
+}
+
+type directive struct {
+	name string
+	args []*argument
+}
+
+const (
+	varValue = iota
+	intValue
+	floatValue
+	stringValue
+	boolValue
+	nullValue
+	enumValue
+	listValue
+	objectValue
+)
+
+type value struct {
+	kind   int
+	raw    string
+	list   []*value
+	fields []*argument
+}
+
+// resolve gives the go value of v the way encoding/json with UseNumber would
+// decode it, so arguments and variables look the same to the resolvers.
+func (v *value) resolve(vars map[string]interface{}) interface{} {
+	switch v.kind {
+	case varValue:
+		return vars[v.raw]
+	case intValue, floatValue:
+		return json.Number(v.raw)
+	case stringValue, enumValue:
+		return v.raw
+	case boolValue:
+		return v.raw == "true"
+	case listValue:
+		o := make([]interface{}, 0, len(v.list))
+		for _, x := range v.list {
+			o = append(o, x.resolve(vars))
+		}
+		return o
+	case objectValue:
+		o := make(map[string]interface{})
+		for _, x := range v.fields {
+			o[x.name] = x.value.resolve(vars)
+		}
+		return o
+	}
+	return nil
+}
+
+func (v *value) missing(vars map[string]interface{}) bool {
+	if v.kind != varValue {
+		return false
+	}
+	_, ok := vars[v.raw]
+	return !ok
+}
+
+type parser struct {
+	l   *lexer
+	tok token
+}
+
+func parse(src string) (*document, error) {
+	p := &parser{l: &lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &document{frags: make(map[string]*fragment)}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek("{"):
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.ops = append(doc.ops, &operation{kind: "query", sels: sels})
+		case p.tok.kind == tokName && p.tok.val == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.frags[f.name]; ok {
+				return nil, fmt.Errorf("fragment %s is defined more than once", f.name)
+			}
+			doc.frags[f.name] = f
+		case p.tok.kind == tokName && (p.tok.val == "query" || p.tok.val == "mutation" || p.tok.val == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.ops = append(doc.ops, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.ops) == 0 {
+		return nil, fmt.Errorf("no operations in the document")
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	t, err := p.l.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.val == punct
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return p.l.errorf(p.tok.pos, "unexpected end of document")
+	}
+	return p.l.errorf(p.tok.pos, "unexpected %q", p.tok.val)
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		if p.tok.kind == tokEOF {
+			return p.l.errorf(p.tok.pos, "expected %q got end of document", punct)
+		}
+		return p.l.errorf(p.tok.pos, "expected %q got %q", punct, p.tok.val)
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected()
+	}
+	n := p.tok.val
+	return n, p.advance()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.tok.val}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName {
+		op.name = p.tok.val
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek(")") {
+			v, err := p.varDef()
+			if err != nil {
+				return nil, err
+			}
+			op.vars = append(op.vars, v)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	dirs, err := p.directives()
+	if err != nil {
+		return nil, err
+	}
+	op.dirs = dirs
+	op.sels, err = p.selectionSet()
+	return op, err
+}
+
+func (p *parser) varDef() (*varDef, error) {
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	typ, err := p.typeRef()
+	if err != nil {
+		return nil, err
+	}
+	v := &varDef{name: name, typ: typ}
+	if p.peek("=") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		v.def, err = p.value(true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (p *parser) typeRef() (*typeRef, error) {
+	t := &typeRef{}
+	if p.peek("[") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		of, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		t.list = of
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		t.name = name
+	}
+	if p.peek("!") {
+		t.nonNull = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.l.errorf(p.tok.pos, "fragments can not be named on")
+	}
+	if p.tok.kind != tokName || p.tok.val != "on" {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	on, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f := &fragment{name: name, on: on}
+	f.dirs, err = p.directives()
+	if err != nil {
+		return nil, err
+	}
+	f.sels, err = p.selectionSet()
+	return f, err
+}
+
+func (p *parser) selectionSet() ([]*selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var o []*selection
+	for !p.peek("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, s)
+	}
+	if len(o) == 0 {
+		return nil, p.l.errorf(p.tok.pos, "empty selection set")
+	}
+	return o, p.advance()
+}
+
+func (p *parser) selection() (*selection, error) {
+	s := &selection{}
+	var err error
+	if p.peek("...") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		switch {
+		case p.tok.kind == tokName && p.tok.val == "on":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			s.inline = true
+			s.on, err = p.name()
+			if err != nil {
+				return nil, err
+			}
+		case p.tok.kind == tokName:
+			s.spread = p.tok.val
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			s.dirs, err = p.directives()
+			return s, err
+		default:
+			s.inline = true
+		}
+		s.dirs, err = p.directives()
+		if err != nil {
+			return nil, err
+		}
+		s.sels, err = p.selectionSet()
+		return s, err
+	}
+	s.name, err = p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		s.alias = s.name
+		s.name, err = p.name()
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.args, err = p.arguments(false)
+	if err != nil {
+		return nil, err
+	}
+	s.dirs, err = p.directives()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		s.sels, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *parser) arguments(constant bool) ([]*argument, error) {
+	if !p.peek("(") {
+		return nil, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var o []*argument
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, &argument{name: name, value: v})
+	}
+	return o, p.advance()
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var o []*directive
+	for p.peek("@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, &directive{name: name, args: args})
+	}
+	return o, nil
+}
+
+func (p *parser) value(constant bool) (*value, error) {
+	t := p.tok
+	v := &value{raw: t.val}
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return &value{kind: varValue, raw: name}, nil
+	case p.peek("["):
+		v.kind = listValue
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek("]") {
+			x, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			v.list = append(v.list, x)
+		}
+		return v, p.advance()
+	case p.peek("{"):
+		v.kind = objectValue
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			x, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			v.fields = append(v.fields, &argument{name: name, value: x})
+		}
+		return v, p.advance()
+	case t.kind == tokInt:
+		v.kind = intValue
+	case t.kind == tokFloat:
+		v.kind = floatValue
+	case t.kind == tokString:
+		v.kind = stringValue
+	case t.kind == tokName && (t.val == "true" || t.val == "false"):
+		v.kind = boolValue
+	case t.kind == tokName && t.val == "null":
+		v.kind = nullValue
+	case t.kind == tokName:
+		v.kind = enumValue
+	default:
+		return nil, p.unexpected()
+	}
+	return v, p.advance()
+}
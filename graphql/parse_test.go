@@ -0,0 +1,73 @@
+package graphql
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+# a comment
+query Users($limit: Int = 10, $name: String!) @live {
+	all: users(limit: $limit, filters: {tags: ["a", "b"], on: true}) {
+		id
+		...userFields
+		... on User @include(if: false) { email }
+	}
+}
+
+fragment userFields on User {
+	username
+	bio(format: """
+		block
+	""")
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.ops) != 1 || len(doc.frags) != 1 {
+		t.Fatalf("expected one operation and one fragment got %d %d", len(doc.ops), len(doc.frags))
+	}
+	op := doc.ops[0]
+	if op.kind != "query" || op.name != "Users" || len(op.vars) != 2 || len(op.dirs) != 1 {
+		t.Fatalf("unexpected operation %+v", op)
+	}
+	if op.vars[0].def == nil || op.vars[1].typ.String() != "String!" {
+		t.Errorf("unexpected variables %+v %+v", op.vars[0], op.vars[1])
+	}
+	all := op.sels[0]
+	if all.key() != "all" || all.name != "users" || len(all.args) != 2 || len(all.sels) != 3 {
+		t.Fatalf("unexpected selection %+v", all)
+	}
+	if all.sels[1].spread != "userFields" {
+		t.Errorf("expected a fragment spread got %+v", all.sels[1])
+	}
+	if !all.sels[2].inline || all.sels[2].on != "User" || len(all.sels[2].dirs) != 1 {
+		t.Errorf("expected an inline fragment got %+v", all.sels[2])
+	}
+	bio := doc.frags["userFields"].sels[1]
+	if v := bio.args[0].value.resolve(nil); v != "block" {
+		t.Errorf("expected block got %q", v)
+	}
+
+	for _, src := range []string{
+		"{ users ",
+		"query ($a: ) { id }",
+		`{ users(name: "x) }`,
+		"{ ...on }",
+		"{ }",
+		"{ a(x: 1.) }",
+		"{ a(x: 01) }",
+		"{ a(x: 1e) }",
+		`{ a(x: "\q") }`,
+		`{ a(x: "\u00zz") }`,
+		`{ a(x: """open) }`,
+		"query ($v: Int = $w) { a }",
+		"{ a } fragment on on User { b }",
+		"{ a } fragment f on User { b } fragment f on User { c }",
+		"fragment f on User { b }",
+		"{ a ? }",
+		"",
+	} {
+		if _, err := parse(src); err == nil {
+			t.Errorf("expected an error for %q", src)
+		}
+	}
+}
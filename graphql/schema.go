@@ -0,0 +1,140 @@
+// Package graphql parses GraphQL requests and executes them against a schema
+// built in code, with the introspection types graphiql and other tools ask for.
+package graphql
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Type kinds as reported by introspection.
+const (
+	Scalar      = "SCALAR"
+	Object      = "OBJECT"
+	Enum        = "ENUM"
+	InputObject = "INPUT_OBJECT"
+	List        = "LIST"
+	NonNullKind = "NON_NULL"
+)
+
+// Resolver returns the value of a field of src.
+type Resolver func(src interface{}, args map[string]interface{}) (interface{}, error)
+
+type Type struct {
+	Kind   string
+	Name   string
+	Desc   string
+	Fields []*Field
+	Inputs []*Input
+	Enums  []string
+	OfType *Type
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case NonNullKind:
+		return t.OfType.String() + "!"
+	case List:
+		return "[" + t.OfType.String() + "]"
+	}
+	return t.Name
+}
+
+// Field returns the field called name or nil.
+func (t *Type) Field(name string) *Field {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+type Field struct {
+	Name    string
+	Desc    string
+	Args    []*Input
+	Type    *Type
+	Resolve Resolver
+}
+
+// Input is an argument of a field or a field of an input object. Default is
+// in GraphQL syntax.
+type Input struct {
+	Name    string
+	Desc    string
+	Type    *Type
+	Default string
+}
+
+func NonNull(t *Type) *Type {
+	return &Type{Kind: NonNullKind, OfType: t}
+}
+
+func ListOf(t *Type) *Type {
+	return &Type{Kind: List, OfType: t}
+}
+
+type directiveDef struct {
+	name      string
+	desc      string
+	locations []string
+	args      []*Input
+}
+
+// Schema holds the named types. Query must be added and set before executing,
+// Mutation is optional.
+type Schema struct {
+	Query    *Type
+	Mutation *Type
+
+	// Extensions adds the extensions of the errors returned by resolvers.
+	Extensions func(error) map[string]interface{}
+
+	types      map[string]*Type
+	directives []*directiveDef
+	meta       map[string]*Field
+}
+
+// NewSchema returns a schema with the built in scalars and the introspection
+// types.
+func NewSchema() *Schema {
+	s := &Schema{types: make(map[string]*Type)}
+	for _, v := range []string{"Int", "Float", "String", "Boolean", "ID"} {
+		s.types[v] = &Type{Kind: Scalar, Name: v}
+	}
+	introspectionTypes(s)
+	return s
+}
+
+// Add registers named types, it fails when a name is already taken.
+func (s *Schema) Add(types ...*Type) error {
+	for _, t := range types {
+		if _, ok := s.types[t.Name]; ok {
+			return fmt.Errorf("graphql: type %s is defined more than once", t.Name)
+		}
+	}
+	for _, t := range types {
+		s.types[t.Name] = t
+	}
+	return nil
+}
+
+func (s *Schema) add(t *Type) *Type {
+	s.types[t.Name] = t
+	return t
+}
+
+// Lookup returns the type called name or nil.
+func (s *Schema) Lookup(name string) *Type {
+	return s.types[name]
+}
+
+func (s *Schema) typeNames() []string {
+	var o []string
+	for k := range s.types {
+		o = append(o, k)
+	}
+	sort.Strings(o)
+	return o
+}
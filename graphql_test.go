@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAPI_graphql(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"user":{"username":"gernest","email":"gernest@example.com","profile":{"country":"Tanzania"}}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{"username":"gernest","profile":{"country":"Tanzania"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	gql := func(query string, vars map[string]interface{}) string {
+		b, _ := json.Marshal(map[string]interface{}{"query": query, "variables": vars})
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(string(b))))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+	sample := []struct {
+		query  string
+		vars   map[string]interface{}
+		expect string
+	}{
+		{
+			query:  `{ users { id username profile { country users { username } } } }`,
+			expect: `{"data":{"users":[{"id":2,"username":"gernest","profile":{"country":"Tanzania","users":[{"username":"gernest"}]}}]}}`,
+		},
+		{
+			query:  `mutation ($u: UserInput!) { created: createUser(input: $u) { id username } }`,
+			vars:   map[string]interface{}{"u": map[string]interface{}{"username": "john", "email": "john@example.com"}},
+			expect: `{"data":{"created":{"id":3,"username":"john"}}}`,
+		},
+		{
+			query:  `query ($skip: Boolean!) { users(username: "john", limit: 1) { ...f } } fragment f on User { id email @skip(if: $skip) }`,
+			vars:   map[string]interface{}{"skip": true},
+			expect: `{"data":{"users":[{"id":3}]}}`,
+		},
+		{
+			query:  `{ users(limit: 1, offset: 1) { username } user(id: 2) { __typename username } }`,
+			expect: `{"data":{"users":[{"username":"gernest"}],"user":{"__typename":"User","username":"gernest"}}}`,
+		},
+		{
+			query:  `mutation { updateUser(id: 3, input: {email: "j@example.com"}) { email } deleteUser(id: 3) }`,
+			expect: `{"data":{"updateUser":{"email":"j@example.com"},"deleteUser":true}}`,
+		},
+		{
+			query:  `{ user(id: 3) { username } }`,
+			expect: `{"data":{"user":null}}`,
+		},
+		{
+			query:  `mutation { createUser(input: {username: 1}) { id } }`,
+			expect: `{"data":{"createUser":null},"errors":[{"message":"invalid fields username: expected string got json.Number","path":["createUser"],"extensions":{"fields":{"username":"expected string got json.Number"}}}]}`,
+		},
+		{
+			query:  `{ __type(name: "Profile") { kind fields { name type { kind ofType { name } } } } __schema { queryType { name } mutationType { name } } }`,
+			expect: `{"data":{"__type":{"kind":"OBJECT","fields":[{"name":"id","type":{"kind":"NON_NULL","ofType":{"name":"Int"}}},{"name":"country","type":{"kind":"SCALAR","ofType":null}},{"name":"users","type":{"kind":"NON_NULL","ofType":{"name":null}}}]},"__schema":{"queryType":{"name":"Query"},"mutationType":{"name":"Mutation"}}}}`,
+		},
+	}
+	for _, v := range sample {
+		got := gql(v.query, v.vars)
+		if got != v.expect {
+			t.Errorf("%s\nexpected %s\ngot      %s", v.query, v.expect, got)
+		}
+	}
+
+	got := gql(`{ __type(name: "UserInput") { kind } }`, nil)
+	if got != `{"data":{"__type":{"kind":"INPUT_OBJECT"}}}` {
+		t.Errorf("unexpected introspection result %s", got)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("mutation { deleteUser(id: 2) }"), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d %s", http.StatusBadRequest, w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ users { id } }"), nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"data":{"users":[{"id":2}]}}` {
+		t.Errorf("unexpected GET result %d %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"post":{"title":"hello"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	if got := gql(`{ posts { title } }`, nil); got != `{"data":{"posts":[]}}` {
+		t.Errorf("expected the schema to be regenerated got %s", got)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"query":{"q":"x"},"string":{"s":"x"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	got = gql(`mutation { createStringModel(input: {s: "a"}) { __typename s } }`, nil)
+	if got != `{"data":{"createStringModel":{"__typename":"StringModel","s":"a"}}}` {
+		t.Errorf("expected strings to be StringModel got %s", got)
+	}
+	got = gql(`{ queries { __typename } strings { s } __schema { queryType { name } } }`, nil)
+	if got != `{"data":{"queries":[],"strings":[{"s":"a"}],"__schema":{"queryType":{"name":"Query"}}}}` {
+		t.Errorf("expected queries to leave Query alone got %s", got)
+	}
+}
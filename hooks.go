@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cznic/ql"
+)
+
+const hookRead = "read"
+
+const hookAnyModel = "*"
+
+// hookContext is what a hook sees of an operation. Hooks of writes run inside
+// its transaction, so they must use these methods rather than the crud ones.
+type hookContext struct {
+	Model string
+	Op    string
+	ID    int64
+	Props modelProps
+	tx    *crudTx
+	c     *crud
+}
+
+// Exec runs src in the transaction of the operation, if there is one.
+func (h *hookContext) Exec(src string, args ...interface{}) ([]ql.Recordset, error) {
+	if h.tx != nil {
+		return h.tx.run(src, args...)
+	}
+	rs, _, err := h.c.db.Run(ql.NewRWCtx(), src, args...)
+	return rs, err
+}
+
+func (h *hookContext) Get(model string, id int64) (modelProps, error) {
+	ctx := ql.NewRWCtx()
+	if h.tx != nil {
+		ctx = h.tx.ctx
+	}
+	o, err := h.c.getByIDCtx(ctx, model, id, scopeLive)
+	if err != nil || len(o) == 0 {
+		return nil, err
+	}
+	return o[0], nil
+}
+
+func (h *hookContext) Create(model string, props modelProps) (modelProps, error) {
+	if h.tx != nil {
+		return h.c.createTx(h.tx, model, props)
+	}
+	return h.c.create(model, props)
+}
+
+func (h *hookContext) Update(model string, id int64, props modelProps) (modelProps, error) {
+	if h.tx != nil {
+		return h.c.updateTx(h.tx, model, id, props)
+	}
+	return h.c.update(model, id, props)
+}
+
+func (h *hookContext) Delete(model string, id int64) error {
+	if h.tx != nil {
+		return h.c.deleteTx(h.tx, model, id)
+	}
+	return h.c.deleteByID(model, id, "")
+}
+
+type hookFunc func(h *hookContext) error
+
+// hookError aborts an operation from a hook and is answered with code.
+type hookError struct {
+	code int
+	err  error
+}
+
+func (e *hookError) Error() string {
+	return e.err.Error()
+}
+
+func abortHook(code int, format string, args ...interface{}) error {
+	return &hookError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+type crudHooks struct {
+	mu     sync.RWMutex
+	before map[string][]hookFunc
+	after  map[string][]hookFunc
+}
+
+func newCrudHooks() *crudHooks {
+	return &crudHooks{
+		before: make(map[string][]hookFunc),
+		after:  make(map[string][]hookFunc),
+	}
+}
+
+func (h *crudHooks) add(m map[string][]hookFunc, model, op string, fn hookFunc) {
+	h.mu.Lock()
+	m[model+":"+op] = append(m[model+":"+op], fn)
+	h.mu.Unlock()
+}
+
+func (h *crudHooks) get(after bool, model, op string) []hookFunc {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	m := h.before
+	if after {
+		m = h.after
+	}
+	var o []hookFunc
+	o = append(o, m[hookAnyModel+":"+op]...)
+	return append(o, m[model+":"+op]...)
+}
+
+// before registers fn to run before op on model, * matches every model.
+func (c *crud) before(model, op string, fn hookFunc) {
+	if c.hooks == nil {
+		c.hooks = newCrudHooks()
+	}
+	c.hooks.add(c.hooks.before, model, op, fn)
+}
+
+func (c *crud) after(model, op string, fn hookFunc) {
+	if c.hooks == nil {
+		c.hooks = newCrudHooks()
+	}
+	c.hooks.add(c.hooks.after, model, op, fn)
+}
+
+func (a *api) before(model, op string, fn hookFunc) {
+	a.hooks.add(a.hooks.before, model, op, fn)
+}
+
+func (a *api) after(model, op string, fn hookFunc) {
+	a.hooks.add(a.hooks.after, model, op, fn)
+}
+
+func runHooks(fns []hookFunc, h *hookContext) error {
+	for _, fn := range fns {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *crud) runBefore(h *hookContext) error {
+	h.c = c
+	return runHooks(c.hooks.get(false, h.Model, h.Op), h)
+}
+
+func (c *crud) runAfter(h *hookContext) error {
+	h.c = c
+	return runHooks(c.hooks.get(true, h.Model, h.Op), h)
+}
+
+func (c *crud) beforeRead(model string, id int64) error {
+	return c.runBefore(&hookContext{Model: model, Op: hookRead, ID: id})
+}
+
+func (c *crud) afterRead(model string, props modelProps) error {
+	id, _ := props["id"].(int64)
+	return c.runAfter(&hookContext{Model: model, Op: hookRead, ID: id, Props: props})
+}
+
+func (c *crud) readRow(model string, names []string, data []interface{}) ([]string, []interface{}, error) {
+	p := make(modelProps)
+	for k, v := range data {
+		p[names[k]] = v
+	}
+	if err := c.afterRead(model, p); err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool)
+	var on []string
+	var od []interface{}
+	for _, k := range names {
+		if v, ok := p[k]; ok {
+			seen[k] = true
+			on = append(on, k)
+			od = append(od, v)
+		}
+	}
+	var extra []string
+	for k := range p {
+		if !seen[k] {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		on = append(on, k)
+		od = append(od, p[k])
+	}
+	return on, od, nil
+}
+
+// crudTx publishes its changes once it commits.
+type crudTx struct {
+	c       *crud
+	ctx     *ql.TCtx
+	pending []*change
+}
+
+func (c *crud) begin() (*crudTx, error) {
+	tx := &crudTx{c: c, ctx: ql.NewRWCtx()}
+	if _, err := tx.run("begin transaction;"); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (c *crud) inTx(fn func(tx *crudTx) error) error {
+	tx, err := c.begin()
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	return tx.commit()
+}
+
+// run executes src in tx. The write templates have no transaction of their
+// own, the in memory backend of ql forgets how to undo a nested transaction
+// once it commits.
+func (tx *crudTx) run(src string, args ...interface{}) ([]ql.Recordset, error) {
+	rs, _, err := tx.c.db.Run(tx.ctx, src, args...)
+	return rs, err
+}
+
+func (tx *crudTx) commit() error {
+	if _, err := tx.run("commit;"); err != nil {
+		return err
+	}
+	for _, e := range tx.pending {
+		tx.c.changes.publish(e.Model, e.ID, e.Op, e.Record)
+	}
+	tx.pending = nil
+	return nil
+}
+
+func (tx *crudTx) rollback() {
+	_, _ = tx.run("rollback;")
+	tx.pending = nil
+}
+
+func (tx *crudTx) changed(model string, id int64, op string, record modelProps) {
+	if !tx.c.changes.active() {
+		return
+	}
+	if record == nil {
+		o, err := tx.c.getByIDCtx(tx.ctx, model, id, scopeAll)
+		if err != nil || len(o) == 0 {
+			return
+		}
+		record = o[0]
+	}
+	tx.pending = append(tx.pending, &change{Model: model, ID: id, Op: op, Record: record})
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPI_hooks(t *testing.T) {
+	a, ts, done := testChangesAPI(t)
+	defer done()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	var ops []string
+	a.before(hookAnyModel, changeCreate, func(h *hookContext) error {
+		ops = append(ops, "before "+h.Model)
+		return nil
+	})
+	a.before("users", changeCreate, func(h *hookContext) error {
+		if h.Props["username"] == "root" {
+			return abortHook(http.StatusForbidden, "username %s is reserved", h.Props["username"])
+		}
+		if _, ok := h.Props["email"]; !ok {
+			h.Props["email"] = fmt.Sprintf("%s@example.com", h.Props["username"])
+		}
+		return nil
+	})
+	a.after("users", changeCreate, func(h *hookContext) error {
+		ops = append(ops, fmt.Sprintf("after %s %d", h.Model, h.ID))
+		if h.Props["username"] != "fail" {
+			return nil
+		}
+		if _, err := h.Exec("insert into users (username) values ($1);", "audit"); err != nil {
+			return err
+		}
+		return abortHook(http.StatusConflict, "rejected after insert")
+	})
+	a.after("users", hookRead, func(h *hookContext) error {
+		h.Props["handle"] = fmt.Sprintf("@%s", h.Props["username"])
+		return nil
+	})
+	var deleted modelProps
+	a.before("users", changeDelete, func(h *hookContext) error {
+		if h.Props["username"] == "keep" {
+			return abortHook(http.StatusForbidden, "%s can not be deleted", h.Props["username"])
+		}
+		return nil
+	})
+	a.after("users", changeDelete, func(h *hookContext) error {
+		deleted = h.Props
+		return nil
+	})
+
+	var user modelProps
+	d := json.NewDecoder(strings.NewReader(string(testWrite(t, ts, "POST", "/v1/users", `{"username":"gernest"}`))))
+	d.UseNumber()
+	if err := d.Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user["email"] != "gernest@example.com" {
+		t.Errorf("expected a derived email got %v", user)
+	}
+	id, _ := user["id"].(json.Number).Int64()
+	if len(ops) != 2 || ops[0] != "before users" || ops[1] != fmt.Sprintf("after users %d", id) {
+		t.Errorf("unexpected hooks %v", ops)
+	}
+
+	w := do("POST", "/v1/users", `{"username":"root"}`)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "reserved") {
+		t.Errorf("expected %d got %d %s", http.StatusForbidden, w.Code, w.Body)
+	}
+	w = do("POST", "/v1/users", `{"username":"fail"}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected %d got %d %s", http.StatusConflict, w.Code, w.Body)
+	}
+
+	w = do("GET", "/v1/users", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	var l []modelProps
+	if err := json.Unmarshal(w.Body.Bytes(), &l); err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0]["username"] != "gernest" || l[0]["handle"] != "@gernest" {
+		t.Errorf("expected the aborted writes to be rolled back got %s", w.Body)
+	}
+	w = do("GET", fmt.Sprintf("/v1/users/%d", id), "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"handle":"@gernest"`) {
+		t.Errorf("expected the read hook on get got %d %s", w.Code, w.Body)
+	}
+
+	var keep modelProps
+	if err := json.Unmarshal(testWrite(t, ts, "POST", "/v1/users", `{"username":"keep"}`), &keep); err != nil {
+		t.Fatal(err)
+	}
+	if w = do("DELETE", fmt.Sprintf("/v1/users/%v", keep["id"]), ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected %d got %d %s", http.StatusForbidden, w.Code, w.Body)
+	}
+	testWrite(t, ts, "DELETE", fmt.Sprintf("/v1/users/%d", id), "")
+	if deleted["username"] != "gernest" {
+		t.Errorf("expected the deleted record got %v", deleted)
+	}
+
+	w = do("POST", "/graphql", `{"query":"mutation { createUser(input: {username: \"root\"}) { id } }"}`)
+	if !strings.Contains(w.Body.String(), `"extensions":{"status":403}`) {
+		t.Errorf("expected the hook status in the graphql error got %s", w.Body)
+	}
+
+	w = do("POST", "/schema", `{"user":{"username":"gernest","email":"gernest@example.com"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	testWrite(t, ts, "POST", "/v1/users", `{"username":"john"}`)
+	w = do("GET", "/v1/users", "")
+	if !strings.Contains(w.Body.String(), `"handle":"@john"`) {
+		t.Errorf("expected the hooks to survive a schema change got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestCRUD_hooksRollback(t *testing.T) {
+	c := testCrud(t, "create table users (id int64, username string);")
+	o, err := c.create("users", modelProps{"username": "gernest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := o["id"].(int64)
+	for _, op := range []string{changeUpdate, changeDelete} {
+		c.after("users", op, func(h *hookContext) error {
+			return abortHook(http.StatusForbidden, "%s is not allowed", h.Op)
+		})
+	}
+	if _, err = c.update("users", id, modelProps{"username": "john"}); crudErrCode(err) != http.StatusForbidden {
+		t.Errorf("expected the update to be aborted got %v", err)
+	}
+	if err = c.deleteByID("users", id, ""); crudErrCode(err) != http.StatusForbidden {
+		t.Errorf("expected the delete to be aborted got %v", err)
+	}
+	l, err := c.getByID("users", id, scopeLive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0]["username"] != "gernest" {
+		t.Errorf("expected the record to be untouched got %v", l)
+	}
+}
+
+func TestCRUD_hooksInTx(t *testing.T) {
+	c := testCrud(t, `
+	create table users (id int64, username string);
+	create table audits (id int64, user_id int64, op string);`)
+	audit := func(h *hookContext) error {
+		if h.Model == "audits" {
+			return nil
+		}
+		if h.Op == hookRead {
+			o, err := h.Get("users", h.ID)
+			if err != nil || o == nil {
+				return fmt.Errorf("expected to read users %d in the hook got %v", h.ID, err)
+			}
+			return nil
+		}
+		_, err := h.Create("audits", modelProps{"user_id": h.ID, "op": h.Op})
+		return err
+	}
+	for _, op := range []string{changeCreate, changeUpdate, changeDelete, hookRead} {
+		c.after(hookAnyModel, op, audit)
+	}
+	o, err := c.create("users", modelProps{"username": "gernest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := o["id"].(int64)
+	if _, err = c.updateByID("users", id, modelProps{"username": "john"}, `"stale"`); err != errPreconditionFailed {
+		t.Errorf("expected %v got %v", errPreconditionFailed, err)
+	}
+	if _, err = c.updateByID("users", id, modelProps{"username": "john"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.deleteByID("users", id, ""); err != nil {
+		t.Fatal(err)
+	}
+	l, err := c.getAll("audits")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 3 {
+		t.Errorf("expected an audit per write got %v", l)
+	}
+}
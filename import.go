@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cznic/ql"
+	"github.com/urfave/cli"
+)
+
+const defaultImportBatch = 1000
+
+var errImportAborted = errors.New("import aborted")
+
+type importOptions struct {
+	format string
+	batch  int
+	abort  bool
+	strict bool
+}
+
+type importError struct {
+	Line   int               `json:"line"`
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type importResult struct {
+	Inserted int           `json:"inserted"`
+	Failed   int           `json:"failed"`
+	Errors   []importError `json:"errors"`
+}
+
+type importRow struct {
+	line  int
+	props modelProps
+	err   error
+}
+
+func (c *crud) importRows(model string, src io.Reader, opts importOptions) (*importResult, error) {
+	t, ok := c.schema.tables[model]
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", model)
+	}
+	if opts.batch <= 0 {
+		opts.batch = defaultImportBatch
+	}
+	next, err := rowReader(opts.format, src)
+	if err != nil {
+		return nil, err
+	}
+	o := &importResult{}
+	tx := &crudTx{c: c, ctx: ql.NewRWCtx()}
+	var pending int
+	begin := func() error {
+		_, err := tx.run("begin transaction;")
+		return err
+	}
+	if err = begin(); err != nil {
+		return nil, err
+	}
+	fail := func(line int, err error) error {
+		o.Failed++
+		e := importError{Line: line, Error: err.Error()}
+		if v, ok := err.(*validationError); ok {
+			e.Fields = v.fields
+		}
+		o.Errors = append(o.Errors, e)
+		if opts.abort {
+			tx.rollback()
+			o.Inserted = 0
+			return errImportAborted
+		}
+		return nil
+	}
+	for {
+		row, ok := next()
+		if !ok {
+			break
+		}
+		if row.err == nil {
+			row.err = c.validate(model, row.props, opts.strict)
+		}
+		if row.err == nil {
+			if opts.abort {
+				_, row.err = c.createTx(tx, t.name, row.props)
+			} else {
+				row.err = c.insertRow(tx, t, row.props)
+			}
+		}
+		if row.err != nil {
+			if err := fail(row.line, row.err); err != nil {
+				return o, err
+			}
+			continue
+		}
+		o.Inserted++
+		pending++
+		if !opts.abort && pending >= opts.batch {
+			if err = tx.commit(); err != nil {
+				return nil, err
+			}
+			if err = begin(); err != nil {
+				return nil, err
+			}
+			pending = 0
+		}
+	}
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// insertRow nests a transaction in tx, so a failing create hook undoes only
+// this row.
+func (c *crud) insertRow(tx *crudTx, t *table, props modelProps) error {
+	if _, err := tx.run("begin transaction;"); err != nil {
+		return err
+	}
+	n := len(tx.pending)
+	_, err := c.createTx(tx, t.name, props)
+	if err != nil {
+		_, _ = tx.run("rollback;")
+		tx.pending = tx.pending[:n]
+		return err
+	}
+	_, err = tx.run("commit;")
+	return err
+}
+
+func rowReader(format string, src io.Reader) (func() (*importRow, bool), error) {
+	switch format {
+	case formatCSV:
+		return csvRows(src)
+	case formatNDJSON, formatJSON:
+		return ndjsonRows(src), nil
+	}
+	return nil, fmt.Errorf("unsupported import format %q", format)
+}
+
+func csvRows(src io.Reader) (func() (*importRow, bool), error) {
+	r := csv.NewReader(src)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %v", err)
+	}
+	for k, v := range header {
+		header[k] = strings.TrimSpace(v)
+	}
+	line := 1
+	return func() (*importRow, bool) {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil, false
+		}
+		line++
+		if err != nil {
+			if pe, ok := err.(*csv.ParseError); ok {
+				line = pe.Line
+			}
+			return &importRow{line: line, err: err}, true
+		}
+		if len(rec) != len(header) {
+			return &importRow{line: line, err: fmt.Errorf("expected %d values got %d", len(header), len(rec))}, true
+		}
+		p := make(modelProps)
+		for k, v := range rec {
+			if v == "" {
+				continue
+			}
+			setProp(p, header[k], v)
+		}
+		return &importRow{line: line, props: p}, true
+	}, nil
+}
+
+func ndjsonRows(src io.Reader) func() (*importRow, bool) {
+	s := bufio.NewScanner(src)
+	s.Buffer(make([]byte, 64*1024), 16<<20)
+	line := 0
+	return func() (*importRow, bool) {
+		for s.Scan() {
+			line++
+			b := bytes.TrimSpace(s.Bytes())
+			if len(b) == 0 {
+				continue
+			}
+			dec := json.NewDecoder(bytes.NewReader(b))
+			dec.UseNumber()
+			p := make(modelProps)
+			if err := dec.Decode(&p); err != nil {
+				return &importRow{line: line, err: err}, true
+			}
+			return &importRow{line: line, props: p}, true
+		}
+		if err := s.Err(); err != nil {
+			line++
+			return &importRow{line: line, err: err}, true
+		}
+		return nil, false
+	}
+}
+
+func importFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+	t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch t {
+	case "text/csv":
+		return formatCSV
+	}
+	return formatNDJSON
+}
+
+func (c *crud) importHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		batch, _ := strconv.Atoi(r.URL.Query().Get("batch"))
+		o, err := c.importRows(model, r.Body, importOptions{
+			format: importFormat(r),
+			batch:  batch,
+			abort:  queryBool(r, "abort"),
+			strict: queryBool(r, "strict"),
+		})
+		if err != nil {
+			if err == errImportAborted {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				b, _ := json.Marshal(o)
+				_, _ = w.Write(b)
+				return
+			}
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+		jsonRes(w, o)
+	}
+}
+
+func importParams(t *table) []param {
+	return []param{
+		{
+			Name:    "format",
+			Type:    "string",
+			Desc:    "csv or ndjson, defaults to the request content type",
+			Default: formatNDJSON,
+		},
+		{
+			Name:    "batch",
+			Type:    "int",
+			Desc:    "number of " + t.name + " objects inserted per transaction",
+			Default: defaultImportBatch,
+		},
+		{
+			Name:    "abort",
+			Type:    "bool",
+			Desc:    "roll back the whole import on the first failing line",
+			Default: false,
+		},
+	}
+}
+
+func importCommand(ctx *cli.Context) error {
+	model := ctx.String("model")
+	file := ctx.String("file")
+	if model == "" || file == "" {
+		return errors.New("both --model and --file are required")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	format := ctx.String("format")
+	if format == "" {
+		format = formatNDJSON
+		if strings.EqualFold(filepath.Ext(file), ".csv") {
+			format = formatCSV
+		}
+	}
+	u := fmt.Sprintf("%s/v%s/%s/_import?format=%s&batch=%d&abort=%v",
+		strings.TrimSuffix(ctx.String("baseurl"), "/"), activeVersion, model,
+		format, ctx.Int("batch"), ctx.Bool("abort"),
+	)
+	res, err := http.Post(u, formatTypes[format], f)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	_, err = io.Copy(os.Stdout, res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("import failed: %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cznic/ql"
+)
+
+func importCrud(t *testing.T) (*crud, func()) {
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = db.Run(ql.NewRWCtx(), `
+	begin transaction;
+		create table users(
+			id int64,
+			name string,
+			age int64,
+		);
+	commit;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, func() {
+		_ = db.Close()
+	}
+}
+
+func TestCRUD_importCSV(t *testing.T) {
+	c, done := importCrud(t)
+	defer done()
+	src := `name,age
+gernest,30
+geofrey,old
+ernest,
+`
+	o, err := c.importRows("users", strings.NewReader(src), importOptions{format: formatCSV, batch: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Inserted != 2 || o.Failed != 1 {
+		t.Errorf("expected 2 inserted 1 failed got %d %d", o.Inserted, o.Failed)
+	}
+	if len(o.Errors) != 1 || o.Errors[0].Line != 3 {
+		t.Fatalf("expected an error on line 3 got %v", o.Errors)
+	}
+	if _, ok := o.Errors[0].Fields["age"]; !ok {
+		t.Errorf("expected age to fail got %v", o.Errors[0].Fields)
+	}
+	n, err := c.count("users", &listQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 got %d", n)
+	}
+	all, _ := c.getAll("users")
+	for _, v := range all {
+		if v["id"] == nil {
+			t.Errorf("expected id to be set on %v", v)
+		}
+	}
+}
+
+func TestCRUD_importNDJSON(t *testing.T) {
+	c, done := importCrud(t)
+	defer done()
+	src := `{"name":"gernest","age":30}
+
+{"name":"geofrey","age":
+{"name":"ernest","age":"31"}
+`
+	o, err := c.importRows("users", strings.NewReader(src), importOptions{format: formatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Inserted != 2 || o.Failed != 1 {
+		t.Errorf("expected 2 inserted 1 failed got %d %d", o.Inserted, o.Failed)
+	}
+	if len(o.Errors) != 1 || o.Errors[0].Line != 3 {
+		t.Errorf("expected an error on line 3 got %v", o.Errors)
+	}
+
+	o, err = c.importRows("users", strings.NewReader(src), importOptions{format: formatNDJSON, abort: true})
+	if err != errImportAborted {
+		t.Fatalf("expected %v got %v", errImportAborted, err)
+	}
+	if o.Inserted != 0 {
+		t.Errorf("expected nothing inserted got %d", o.Inserted)
+	}
+	n, err := c.count("users", &listQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected aborted import to be rolled back got %d records", n)
+	}
+}
+
+func TestCRUD_importHooks(t *testing.T) {
+	c, done := importCrud(t)
+	defer done()
+	c.after("users", changeCreate, func(h *hookContext) error {
+		if h.Props["name"] != "ernest" {
+			return nil
+		}
+		if _, err := h.Exec("insert into users (name) values ($1);", "audit"); err != nil {
+			return err
+		}
+		return abortHook(http.StatusConflict, "%s is rejected", h.Props["name"])
+	})
+	src := `{"name":"gernest","age":30}
+{"name":"ernest","age":31}
+`
+	o, err := c.importRows("users", strings.NewReader(src), importOptions{format: formatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Inserted != 1 || o.Failed != 1 {
+		t.Errorf("expected 1 inserted 1 failed got %d %d", o.Inserted, o.Failed)
+	}
+	l, err := c.list("users", &listQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 || l[0]["name"] != "gernest" {
+		t.Errorf("expected the rejected row to be rolled back got %v", l)
+	}
+}
+
+func TestCRUD_importRelated(t *testing.T) {
+	c := testCrud(t, `
+	create table users (id int64, username string);
+	create table products (id int64, name string, users_id int64);
+	`)
+	src := `{"id":99,"name":"maize","user":{"username":"gernest"}}
+{"id":99,"name":"beans"}
+`
+	o, err := c.importRows("products", strings.NewReader(src), importOptions{format: formatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Inserted != 2 {
+		t.Fatalf("expected 2 inserted got %d %v", o.Inserted, o.Errors)
+	}
+	all, err := c.getAll("products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[interface{}]bool)
+	for _, v := range all {
+		ids[v["id"]] = true
+		if v["name"] == "maize" && v["users_id"] == nil {
+			t.Errorf("expected the user to be created with %v", v)
+		}
+	}
+	if len(ids) != 2 || ids[int64(99)] {
+		t.Errorf("expected the client ids to be replaced got %v", all)
+	}
+	if n, _ := c.count("users", &listQuery{}); n != 1 {
+		t.Errorf("expected 1 user got %d", n)
+	}
+}
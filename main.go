@@ -36,6 +36,86 @@ func main() {
 					Value:  "http://localhost:8090",
 					EnvVar: "QLFU_BASEURL",
 				},
+				cli.StringFlag{
+					Name:   "schema",
+					Usage:  "ql file with create table and create index statements to start with",
+					EnvVar: "QLFU_SCHEMA",
+				},
+				cli.BoolFlag{
+					Name:   "query-readonly",
+					Usage:  "reject statements that modify the database on POST /query",
+					EnvVar: "QLFU_QUERY_READONLY",
+				},
+				cli.DurationFlag{
+					Name:   "query-timeout",
+					Usage:  "maximum time a POST /query request may take",
+					Value:  defaultQueryTimeout,
+					EnvVar: "QLFU_QUERY_TIMEOUT",
+				},
+				cli.IntFlag{
+					Name:   "query-limit",
+					Usage:  "maximum number of rows returned per result set on POST /query",
+					Value:  defaultQueryLimit,
+					EnvVar: "QLFU_QUERY_LIMIT",
+				},
+			},
+		},
+		{
+			Name:   "import",
+			Usage:  "bulk import csv or ndjson files into a running qlfu server",
+			Action: importCommand,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "baseurl",
+					Usage:  "base url of the running qlfu server",
+					Value:  "http://localhost:8090",
+					EnvVar: "QLFU_BASEURL",
+				},
+				cli.StringFlag{
+					Name:  "model",
+					Usage: "the model to import into e.g users",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "csv or ndjson file with the records to import",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "csv or ndjson, guessed from the file extension when empty",
+				},
+				cli.IntFlag{
+					Name:  "batch",
+					Usage: "number of records inserted per transaction",
+					Value: defaultImportBatch,
+				},
+				cli.BoolFlag{
+					Name:  "abort",
+					Usage: "roll back the whole import on the first failing line",
+				},
+			},
+		},
+		{
+			Name:  "gen",
+			Usage: "generate code from the schema of a running qlfu server or a ql file",
+			Subcommands: []cli.Command{
+				{
+					Name:   "go-client",
+					Usage:  "typed go client for the generated api",
+					Action: genGoClientCommand,
+					Flags:  append(genFlags("client"), genPackageFlag),
+				},
+				{
+					Name:   "ts-client",
+					Usage:  "typescript interfaces and fetch client for the generated api",
+					Action: genTSClientCommand,
+					Flags:  genFlags("client"),
+				},
+				{
+					Name:   "structs",
+					Usage:  "go structs with ql tags and the migration of the schema",
+					Action: genStructsCommand,
+					Flags:  append(genFlags("models"), genPackageFlag),
+				},
 			},
 		},
 	}
@@ -56,5 +136,16 @@ func serv(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if f := ctx.String("schema"); f != "" {
+		err = a.loadSchemaFile(f)
+		if err != nil {
+			return err
+		}
+	}
+	a.queryConfig = queryConfig{
+		readOnly: ctx.Bool("query-readonly"),
+		timeout:  ctx.Duration("query-timeout"),
+		limit:    ctx.Int("query-limit"),
+	}
 	return http.ListenAndServe(":8090", a)
 }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	openAPIVersion = "3.1.0"
+	openAPIRefs    = "#/components/schemas/"
+)
+
+type openAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Servers    []openAPIServer                  `json:"servers"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components openAPIComponents                `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas   map[string]*jsonSchema      `json:"schemas"`
+	Responses map[string]*operationResult `json:"responses"`
+}
+
+type operation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*operationParam           `json:"parameters,omitempty"`
+	RequestBody *operationBody              `json:"requestBody,omitempty"`
+	Responses   map[string]*operationResult `json:"responses"`
+}
+
+type operationParam struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *jsonSchema `json:"schema"`
+	Example     interface{} `json:"example,omitempty"`
+}
+
+type operationBody struct {
+	Required bool                    `json:"required"`
+	Content  map[string]*contentType `json:"content"`
+}
+
+type operationResult struct {
+	Ref         string                  `json:"$ref,omitempty"`
+	Description string                  `json:"description,omitempty"`
+	Content     map[string]*contentType `json:"content,omitempty"`
+}
+
+type contentType struct {
+	Schema  *jsonSchema     `json:"schema"`
+	Example json.RawMessage `json:"example,omitempty"`
+}
+
+func (a *api) openAPI() *openAPI {
+	o := &openAPI{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:   "qlfu",
+			Version: a.service.Version,
+		},
+		Servers: []openAPIServer{{URL: fmt.Sprintf("%s/v%s", a.baseURL, a.service.Version)}},
+		Paths:   make(map[string]map[string]*operation),
+		Components: openAPIComponents{
+			Schemas: make(map[string]*jsonSchema),
+			Responses: map[string]*operationResult{
+				"Error": {
+					Description: "error",
+					Content: map[string]*contentType{
+						"application/json": {Schema: errorSchema()},
+					},
+				},
+			},
+		},
+	}
+	for _, t := range a.c.models() {
+		o.Components.Schemas[t.name] = tableJSONSchema(t, openAPIRefs)
+	}
+	for _, e := range a.service.Endpoints {
+		path := openAPIPath(e.Path)
+		if o.Paths[path] == nil {
+			o.Paths[path] = make(map[string]*operation)
+		}
+		o.Paths[path][e.Method] = e.operation()
+	}
+	return o
+}
+
+func (e endpoint) operation() *operation {
+	op := &operation{
+		OperationID: operationID(e.Method, e.Path),
+		Summary:     e.Method + " " + e.Path,
+		Responses:   make(map[string]*operationResult),
+	}
+	parts := strings.Split(strings.Trim(e.Path, "/"), "/")
+	if !strings.HasPrefix(parts[0], "_") {
+		op.Tags = []string{parts[0]}
+	}
+	for _, p := range e.Params {
+		v := &operationParam{
+			Name:        p.Name,
+			In:          "query",
+			Description: p.Desc,
+			Schema:      paramSchema(p.Type),
+			Example:     p.Default,
+			Required:    p.Required,
+		}
+		if pathParam(e, p) {
+			v.In = "path"
+			v.Required = true
+		}
+		op.Parameters = append(op.Parameters, v)
+	}
+	if e.Payload != "" {
+		op.RequestBody = &operationBody{
+			Required: true,
+			Content: map[string]*contentType{
+				"application/json": {
+					Schema:  e.request,
+					Example: json.RawMessage(e.Payload),
+				},
+			},
+		}
+		op.Responses["422"] = errorRef()
+	}
+	op.Responses["200"] = e.result()
+	op.Responses["400"] = errorRef()
+	if strings.Contains(e.Path, "/:id") {
+		op.Responses["404"] = errorRef()
+		if e.Method == methodPut || e.Method == methodDelete {
+			op.Responses["412"] = errorRef()
+		}
+	}
+	if e.Method == methodPut && !strings.Contains(e.Path, "/:id") {
+		op.Responses["409"] = errorRef()
+	}
+	return op
+}
+
+func (e endpoint) result() *operationResult {
+	o := &operationResult{Description: "ok", Content: make(map[string]*contentType)}
+	s := e.response
+	if s == nil {
+		s = &jsonSchema{Type: "object"}
+	}
+	switch {
+	case s.Format == "binary":
+		o.Content["application/octet-stream"] = &contentType{Schema: s}
+	case s.Items != nil && s.Items.Ref != "":
+		for _, f := range []string{formatJSON, formatNDJSON, formatCSV} {
+			o.Content[strings.Split(formatTypes[f], ";")[0]] = &contentType{Schema: s}
+		}
+	default:
+		o.Content["application/json"] = &contentType{Schema: s}
+	}
+	return o
+}
+
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for k, v := range parts {
+		if strings.HasPrefix(v, ":") {
+			parts[k] = "{" + v[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func operationID(method, path string) string {
+	id := method
+	for _, v := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '_'
+	}) {
+		id += goName(v)
+	}
+	return id
+}
+
+func paramSchema(typ string) *jsonSchema {
+	switch {
+	case typ == "bool":
+		return &jsonSchema{Type: "boolean"}
+	case strings.HasPrefix(typ, "int"), strings.HasPrefix(typ, "uint"):
+		return &jsonSchema{Type: "integer"}
+	case strings.HasPrefix(typ, "float"):
+		return &jsonSchema{Type: "number"}
+	}
+	return &jsonSchema{Type: "string"}
+}
+
+func errorRef() *operationResult {
+	return &operationResult{Ref: "#/components/responses/Error"}
+}
+
+func errorSchema() *jsonSchema {
+	return &jsonSchema{
+		Type: "object",
+		Properties: map[string]*jsonSchema{
+			"error":   {Type: "string"},
+			"message": {Type: "string"},
+			"fields":  {Type: "object"},
+		},
+	}
+}
+
+func modelRef(t *table) *jsonSchema {
+	return &jsonSchema{Ref: openAPIRefs + t.name}
+}
+
+func modelList(t *table) *jsonSchema {
+	return &jsonSchema{Type: "array", Items: modelRef(t)}
+}
+
+func objectSchema(props map[string]*jsonSchema) *jsonSchema {
+	return &jsonSchema{Type: "object", Properties: props}
+}
+
+func importResultSchema() *jsonSchema {
+	return objectSchema(map[string]*jsonSchema{
+		"inserted": {Type: "integer"},
+		"failed":   {Type: "integer"},
+		"errors": {Type: "array", Items: objectSchema(map[string]*jsonSchema{
+			"line":   {Type: "integer"},
+			"error":  {Type: "string"},
+			"fields": {Type: "object"},
+		})},
+	})
+}
+
+func upsertResultSchema(t *table) *jsonSchema {
+	return objectSchema(map[string]*jsonSchema{
+		"op":     {Type: "string"},
+		"record": modelRef(t),
+	})
+}
+
+func (a *api) openAPIJSON(w http.ResponseWriter, r *http.Request) {
+	b, err := json.Marshal(a.openAPI())
+	if err != nil {
+		jsonErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+func (a *api) openAPIYAML(w http.ResponseWriter, r *http.Request) {
+	b, err := openAPIToYAML(a.openAPI())
+	if err != nil {
+		jsonErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(b)
+}
+
+// openAPIToYAML goes through json so that the json field names and ordering
+// are kept.
+func openAPIToYAML(o *openAPI) ([]byte, error) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err := yamlValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+// yamlValue reads the next json value from dec, objects come back as
+// yaml.MapSlice to keep their order.
+func yamlValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		o := yaml.MapSlice{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := yamlValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, yaml.MapItem{Key: k, Value: v})
+		}
+		_, err = dec.Token()
+		return o, err
+	case json.Delim('['):
+		o := []interface{}{}
+		for dec.More() {
+			v, err := yamlValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, v)
+		}
+		_, err = dec.Token()
+		return o, err
+	}
+	if n, ok := tok.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		return n.Float64()
+	}
+	return tok, nil
+}
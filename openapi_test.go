@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestOperationID(t *testing.T) {
+	sample := []struct {
+		method, path, id string
+	}{
+		{methodGet, "/users", "getUsers"},
+		{methodGet, "/users/:id", "getUsersID"},
+		{methodPost, "/users/:id/restore", "postUsersIDRestore"},
+		{methodGet, "/users/_count", "getUsersCount"},
+	}
+	for _, v := range sample {
+		if id := operationID(v.method, v.path); id != v.id {
+			t.Errorf("expected %s got %s", v.id, id)
+		}
+	}
+	if p := openAPIPath("/users/:id/avatar"); p != "/users/{id}/avatar" {
+		t.Errorf("expected /users/{id}/avatar got %s", p)
+	}
+}
+
+func TestAPI_openAPI(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	r := httptest.NewRequest("POST", "/schema", strings.NewReader(ddlSample))
+	r.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	o := &openAPI{}
+	if err := json.Unmarshal(w.Body.Bytes(), o); err != nil {
+		t.Fatal(err)
+	}
+	if o.OpenAPI != openAPIVersion {
+		t.Errorf("expected %s got %s", openAPIVersion, o.OpenAPI)
+	}
+	if _, ok := o.Components.Schemas["users"]; !ok {
+		t.Errorf("expected users schema got %v", o.Components.Schemas)
+	}
+	op := o.Paths["/users/{id}"][methodPut]
+	if op == nil {
+		t.Fatalf("expected put /users/{id} got %v", o.Paths)
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].In != "path" || !op.Parameters[0].Required {
+		t.Errorf("expected a required id path parameter got %v", op.Parameters)
+	}
+	if op.RequestBody == nil || op.RequestBody.Content["application/json"].Schema.Ref != openAPIRefs+"users" {
+		t.Errorf("expected a users request body got %v", op.RequestBody)
+	}
+	for _, code := range []string{"200", "404", "412", "422"} {
+		if _, ok := op.Responses[code]; !ok {
+			t.Errorf("expected %s response", code)
+		}
+	}
+	list := o.Paths["/users"][methodGet]
+	if list == nil || list.Responses["200"].Content["text/csv"] == nil {
+		t.Errorf("expected list to offer csv got %v", list)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.yaml", nil))
+	if !strings.HasPrefix(w.Body.String(), "openapi: 3.1.0\n") {
+		t.Errorf("expected yaml document got %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(`{"book":{"title":"qlfu"}}`)))
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	o = &openAPI{}
+	_ = json.Unmarshal(w.Body.Bytes(), o)
+	if _, ok := o.Paths["/books"]; !ok {
+		t.Errorf("expected the document to follow schema changes got %v", o.Paths)
+	}
+	if _, ok := o.Paths["/users"]; ok {
+		t.Error("expected users to be gone")
+	}
+}
+
+func TestOpenAPIToYAML(t *testing.T) {
+	o := &openAPI{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: "qlfu: #1", Version: "1"},
+		Servers: []openAPIServer{{URL: "/v1"}},
+		Paths: map[string]map[string]*operation{
+			"/users": {methodGet: {
+				OperationID: "getUsers",
+				Summary:     "- first line\nsecond: line # not a comment",
+				Tags:        []string{"users", "on", "1e3", "- x", "a: b", "# c", "'q'", ""},
+			}},
+		},
+	}
+	b, err := openAPIToYAML(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "openapi: 3.1.0\ninfo:\n") {
+		t.Errorf("expected the json field order got\n%s", b)
+	}
+	var got interface{}
+	if err = yaml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("%v\n%s", err, b)
+	}
+	j, _ := json.Marshal(o)
+	var expect interface{}
+	_ = json.Unmarshal(j, &expect)
+	if g := fromYAML(got); !reflect.DeepEqual(g, expect) {
+		t.Errorf("expected the yaml to read back as\n%v\ngot\n%v", expect, g)
+	}
+}
+
+// fromYAML turns what yaml decodes into what encoding/json would.
+func fromYAML(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		o := make(map[string]interface{})
+		for k, v := range x {
+			o[fmt.Sprint(k)] = fromYAML(v)
+		}
+		return o
+	case []interface{}:
+		o := []interface{}{}
+		for _, v := range x {
+			o = append(o, fromYAML(v))
+		}
+		return o
+	case int:
+		return float64(x)
+	}
+	return v
+}
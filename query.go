@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	scopeLive = iota
+	scopeAll
+	scopeTrash
+)
+
+type listQuery struct {
+	scope   int
+	filters []*field
+	limit   int
+	offset  int
+}
+
+var errPaging = errors.New("limit and offset are only supported when listing records")
+
+// controlName is the query param carrying the control name for t. Controls can
+// always be sent with a leading underscore, the plain name is a filter when t
+// has a column called that.
+func controlName(t *table, name string) string {
+	if _, ok := t.colID(name); ok {
+		return "_" + name
+	}
+	return name
+}
+
+func controlParam(t *table, values url.Values, name string) string {
+	if v := values.Get("_" + name); v != "" {
+		return v
+	}
+	return values.Get(controlName(t, name))
+}
+
+func scopeFrom(t *table, values url.Values) int {
+	if v, _ := strconv.ParseBool(controlParam(t, values, "with_deleted")); v {
+		return scopeAll
+	}
+	return scopeLive
+}
+
+func (c *crud) listQueryFrom(model string, r *http.Request, paged bool) (*listQuery, error) {
+	t, ok := c.schema.tables[model]
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", model)
+	}
+	values := r.URL.Query()
+	q := &listQuery{scope: scopeFrom(t, values)}
+	var names []string
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	verr := &validationError{}
+	for _, name := range []string{"limit", "offset"} {
+		v := controlParam(t, values, name)
+		if v == "" {
+			continue
+		}
+		if !paged {
+			return nil, errPaging
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			verr.add(controlName(t, name), "expected a positive integer")
+			continue
+		}
+		if name == "limit" {
+			q.limit = n
+		} else {
+			q.offset = n
+		}
+	}
+	for _, name := range names {
+		idx, ok := t.colID(name)
+		if !ok {
+			continue
+		}
+		v, err := coerce(t.columns[idx].typ, values.Get(name))
+		if err != nil {
+			verr.add(name, err.Error())
+			continue
+		}
+		q.filters = append(q.filters, &field{Name: name, value: v})
+	}
+	if verr.failed() {
+		return nil, verr
+	}
+	return q, nil
+}
+
+func (c *crud) where(model string, q *listQuery) (string, []interface{}) {
+	var cond []string
+	var args []interface{}
+	for k, v := range q.filters {
+		cond = append(cond, fmt.Sprintf("%s==$%d", v.Name, k+1))
+		args = append(args, v.value)
+	}
+	if s := c.scopeWhere(model, q.scope); s != "" {
+		cond = append(cond, s)
+	}
+	return strings.Join(cond, " && "), args
+}
+
+func filterParams(t *table) []param {
+	var o []param
+	for _, c := range t.columns {
+		o = append(o, param{
+			Name: c.name,
+			Type: c.typ.String(),
+			Desc: "only " + t.name + " objects with the given " + c.name,
+		})
+	}
+	return append(o, scopeParams(t)...)
+}
+
+func scopeParams(t *table) []param {
+	if !t.softDelete {
+		return nil
+	}
+	return []param{
+		{
+			Name:    controlName(t, "with_deleted"),
+			Type:    "bool",
+			Desc:    "include deleted " + t.name + " objects",
+			Default: false,
+		},
+	}
+}
+
+func pageParams(t *table) []param {
+	return []param{
+		{
+			Name: controlName(t, "limit"),
+			Type: "int",
+			Desc: "maximum number of " + t.name + " objects to return",
+		},
+		{
+			Name:    controlName(t, "offset"),
+			Type:    "int",
+			Desc:    "number of " + t.name + " objects to skip",
+			Default: 0,
+		},
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cznic/ql"
+)
+
+const defaultQueryTimeout = 10 * time.Second
+const defaultQueryLimit = 1000
+
+var errQueryTimeout = errors.New("query timed out")
+
+var errUnbalancedTx = errors.New("every begin transaction needs a matching commit or rollback")
+
+var errQueryBusy = errors.New("a timed out query is still running, try again later")
+
+var schemaStatements = []string{
+	"CREATE TABLE ",
+	"CREATE INDEX ",
+	"CREATE UNIQUE INDEX ",
+	"ALTER TABLE ",
+	"DROP TABLE ",
+	"DROP INDEX ",
+}
+
+type queryConfig struct {
+	readOnly bool
+	timeout  time.Duration
+	limit    int
+}
+
+type queryRequest struct {
+	Query    string        `json:"query"`
+	Args     []interface{} `json:"args"`
+	Explain  bool          `json:"explain"`
+	ReadOnly bool          `json:"read_only"`
+}
+
+type resultSet struct {
+	Fields    []string     `json:"fields"`
+	Rows      []modelProps `json:"rows"`
+	Truncated bool         `json:"truncated"`
+}
+
+type queryResult struct {
+	Results      []*resultSet `json:"results"`
+	Explain      []string     `json:"explain,omitempty"`
+	LastInsertID int64        `json:"last_insert_id"`
+	RowsAffected int64        `json:"rows_affected"`
+	ddl          bool
+}
+
+// queryGuard decides whether a query commits or times out, whichever comes
+// first wins.
+type queryGuard struct {
+	mu       sync.Mutex
+	deadline time.Time
+	done     bool
+	expired  bool
+}
+
+func newQueryGuard(timeout time.Duration) *queryGuard {
+	return &queryGuard{deadline: time.Now().Add(timeout)}
+}
+
+func (g *queryGuard) finish() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.expired || time.Now().After(g.deadline) {
+		g.expired = true
+		return false
+	}
+	g.done = true
+	return true
+}
+
+func (g *queryGuard) expire() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.done {
+		return false
+	}
+	g.expired = true
+	return true
+}
+
+func (g *queryGuard) timedOut() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.expired || time.Now().After(g.deadline)
+}
+
+func hasDDL(l ql.List) bool {
+	for _, v := range strings.Split(l.String(), "\n") {
+		v = strings.TrimSpace(v)
+		for _, s := range schemaStatements {
+			if strings.HasPrefix(v, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func balancedTx(l ql.List) bool {
+	var n int
+	for _, v := range strings.Split(l.String(), "\n") {
+		switch strings.TrimSpace(v) {
+		case "BEGIN TRANSACTION;":
+			n++
+		case "COMMIT;", "ROLLBACK;":
+			n--
+			if n < 0 {
+				return false
+			}
+		}
+	}
+	return n == 0
+}
+
+// runQuery executes q inside a transaction of its own, which is only committed
+// when g has not timed out by the time all results are read.
+func runQuery(db *ql.DB, q *queryRequest, cfg queryConfig, g *queryGuard) (o *queryResult, err error) {
+	src := q.Query
+	if q.Explain {
+		src = "EXPLAIN " + strings.TrimSuffix(strings.TrimSpace(src), ";")
+	}
+	l, err := ql.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	if !balancedTx(l) {
+		return nil, errUnbalancedTx
+	}
+	var ctx *ql.TCtx
+	if !(cfg.readOnly || q.ReadOnly || q.Explain) {
+		ctx = ql.NewRWCtx()
+		if _, _, err = db.Run(ctx, "begin transaction;"); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err == nil && !g.finish() {
+				err = errQueryTimeout
+			}
+			if err != nil {
+				_, _, _ = db.Run(ctx, "rollback;")
+				o = nil
+				return
+			}
+			if _, _, err = db.Run(ctx, "commit;"); err != nil {
+				o = nil
+			}
+		}()
+	}
+	rs, _, err := db.Execute(ctx, l, queryArgs(q.Args)...)
+	if err != nil {
+		return nil, err
+	}
+	o = &queryResult{ddl: ctx != nil && hasDDL(l)}
+	if ctx != nil {
+		o.LastInsertID = ctx.LastInsertID
+		o.RowsAffected = ctx.RowsAffected
+	}
+	for _, r := range rs {
+		names, err := r.Fields()
+		if err != nil {
+			return nil, err
+		}
+		set := &resultSet{Fields: names}
+		err = r.Do(false, func(data []interface{}) (bool, error) {
+			if g.timedOut() {
+				return false, errQueryTimeout
+			}
+			if cfg.limit > 0 && len(set.Rows) >= cfg.limit {
+				set.Truncated = true
+				return false, nil
+			}
+			p := make(modelProps)
+			for k, v := range data {
+				p[names[k]] = v
+			}
+			set.Rows = append(set.Rows, p)
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		o.Results = append(o.Results, set)
+	}
+	if q.Explain && len(o.Results) > 0 {
+		for _, row := range o.Results[0].Rows {
+			for _, v := range row {
+				if s, ok := v.(string); ok {
+					o.Explain = append(o.Explain, s)
+				}
+			}
+		}
+		o.Results = nil
+	}
+	return o, nil
+}
+
+func queryArgs(src []interface{}) []interface{} {
+	var o []interface{}
+	for _, v := range src {
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				v = i
+			} else if f, err := n.Float64(); err == nil {
+				v = f
+			}
+		}
+		o = append(o, v)
+	}
+	return o
+}
+
+func (a *api) query(w http.ResponseWriter, r *http.Request) {
+	q := &queryRequest{}
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	err := dec.Decode(q)
+	if err != nil {
+		jsonErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(q.Query) == "" {
+		jsonErr(w, errors.New("missing query"), http.StatusBadRequest)
+		return
+	}
+	type result struct {
+		o   *queryResult
+		err error
+	}
+	before := a.c.schema.migration(0)
+	g := newQueryGuard(a.queryConfig.timeout)
+	timeout := time.NewTimer(a.queryConfig.timeout)
+	defer timeout.Stop()
+	// ql can not stop a running statement, a query that timed out keeps its
+	// slot until it is rolled back so they can not pile up.
+	select {
+	case a.queries <- struct{}{}:
+	case <-timeout.C:
+		jsonErr(w, errQueryBusy, http.StatusServiceUnavailable)
+		return
+	}
+	ch := make(chan result, 1)
+	go func() {
+		defer func() {
+			<-a.queries
+		}()
+		o, err := runQuery(a.db, q, a.queryConfig, g)
+		ch <- result{o, err}
+	}()
+	var res result
+	select {
+	case res = <-ch:
+	case <-timeout.C:
+		if g.expire() {
+			res.err = errQueryTimeout
+		} else {
+			res = <-ch
+		}
+	}
+	if res.err != nil {
+		code := http.StatusBadRequest
+		if res.err == errQueryTimeout {
+			code = http.StatusGatewayTimeout
+		}
+		jsonErr(w, res.err, code)
+		return
+	}
+	if res.o.ddl {
+		err = a.reloadIfChanged(before)
+		if err != nil {
+			jsonErr(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	jsonRes(w, res.o)
+}
+
+func (a *api) reloadIfChanged(before string) error {
+	c, err := newCrud(a.db)
+	if err != nil {
+		return err
+	}
+	if c.schema.migration(0) == before {
+		return nil
+	}
+	a.c = c
+	return a.load()
+}
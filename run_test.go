@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cznic/ql"
+)
+
+func TestRunQuery(t *testing.T) {
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	cfg := queryConfig{timeout: time.Second, limit: 2}
+	o, err := runQuery(db, &queryRequest{Query: `
+	begin transaction;
+		create table users(name string, age int64);
+		insert into users values("a", 1), ("b", 2), ("c", 3);
+	commit;`}, cfg, newQueryGuard(cfg.timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.RowsAffected != 3 || !o.ddl {
+		t.Errorf("expected 3 rows from ddl got %d %v", o.RowsAffected, o.ddl)
+	}
+
+	o, err = runQuery(db, &queryRequest{
+		Query: "select name from users where age > $1 order by name;",
+		Args:  []interface{}{json.Number("0")},
+	}, cfg, newQueryGuard(cfg.timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Results) != 1 {
+		t.Fatalf("expected 1 result set got %d", len(o.Results))
+	}
+	rs := o.Results[0]
+	if len(rs.Fields) != 1 || rs.Fields[0] != "name" {
+		t.Errorf("expected [name] got %v", rs.Fields)
+	}
+	if len(rs.Rows) != 2 || !rs.Truncated {
+		t.Errorf("expected 2 truncated rows got %d %v", len(rs.Rows), rs.Truncated)
+	}
+	if rs.Rows[0]["name"] != "a" {
+		t.Errorf("expected a got %v", rs.Rows[0]["name"])
+	}
+
+	ro := cfg
+	ro.readOnly = true
+	_, err = runQuery(db, &queryRequest{Query: `
+	begin transaction;
+		delete from users;
+	commit;`}, ro, newQueryGuard(cfg.timeout))
+	if err == nil {
+		t.Error("expected read only mode to reject writes")
+	}
+	_, err = runQuery(db, &queryRequest{Query: "select * from users;", ReadOnly: true}, cfg, newQueryGuard(cfg.timeout))
+	if err != nil {
+		t.Error(err)
+	}
+
+	o, err = runQuery(db, &queryRequest{Query: "select * from users where age > 1;", Explain: true}, cfg, newQueryGuard(cfg.timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Explain) == 0 {
+		t.Error("expected explain output")
+	}
+
+	for _, src := range []string{
+		`begin transaction; insert into users values("d", 4);`,
+		`insert into users values("d", 4); commit;`,
+		`begin transaction; commit; commit; begin transaction;`,
+	} {
+		_, err = runQuery(db, &queryRequest{Query: src}, cfg, newQueryGuard(cfg.timeout))
+		if err != errUnbalancedTx {
+			t.Errorf("%s: expected %v got %v", src, errUnbalancedTx, err)
+		}
+	}
+	_, err = runQuery(db, &queryRequest{Query: `
+	begin transaction;
+		insert into users values("d", 4);
+	commit;`}, cfg, newQueryGuard(-time.Second))
+	if err != errQueryTimeout {
+		t.Errorf("expected %v got %v", errQueryTimeout, err)
+	}
+	o, err = runQuery(db, &queryRequest{Query: "select count(*) from users;"}, cfg, newQueryGuard(cfg.timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := o.Results[0].Rows[0][""]; n != int64(3) {
+		t.Errorf("expected the timed out insert to be rolled back got %v rows", n)
+	}
+}
+
+func TestAPI_query(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	query := func(src string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(&queryRequest{Query: src})
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest("POST", "/query", strings.NewReader(string(b))))
+		return w
+	}
+	if w := query("begin transaction; create table notes (body string); commit;"); w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	if _, ok := a.c.schema.tables["notes"]; !ok {
+		t.Fatal("expected the crud to be rebuilt with notes")
+	}
+	c := a.c
+	if w := query(`begin transaction; insert into notes values ("a"); commit;`); w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	if a.c != c {
+		t.Error("expected the crud to be kept when no ddl ran")
+	}
+
+	a.queries <- struct{}{}
+	a.queryConfig.timeout = 10 * time.Millisecond
+	if w := query("select * from notes;"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d while a query runs got %d %s", http.StatusServiceUnavailable, w.Code, w.Body)
+	}
+	<-a.queries
+}
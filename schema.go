@@ -37,12 +37,17 @@ func schemaFromJSON(src io.Reader) (*dbSchema, error) {
 	if err != nil {
 		return nil, err
 	}
+	keys, err := jsonKeys(b)
+	if err != nil {
+		return nil, err
+	}
 	s := &dbSchema{tables: make(map[string]*table)}
-	for k, v := range o {
-		switch rv := v.(type) {
+	for _, k := range keys[""] {
+		switch rv := o[k].(type) {
 		case map[string]interface{}:
 			t := &table{name: tableName(k)}
-			for nk, nv := range rv {
+			for _, nk := range keys[k] {
+				nv := rv[nk]
 				c := &column{name: nk}
 				switch nv.(type) {
 				case bool:
@@ -50,11 +55,7 @@ func schemaFromJSON(src io.Reader) (*dbSchema, error) {
 				case float64:
 					c.typ = ql.Float64
 				case string:
-					if _, ok := toTime(nv.(string)); ok {
-						c.typ = ql.Time
-					} else {
-						c.typ = ql.String
-					}
+					c.typ = stringType(nv.(string))
 				case nil:
 					return nil, fmt.Errorf("%s.%v : null objects not supported",
 						k, nk,
@@ -65,7 +66,8 @@ func schemaFromJSON(src io.Reader) (*dbSchema, error) {
 					)
 				case map[string]interface{}:
 					relTable := &table{name: tableName(nk)}
-					for relKey, relVal := range nv.(map[string]interface{}) {
+					for _, relKey := range keys[k+"."+nk] {
+						relVal := nv.(map[string]interface{})[relKey]
 						rc := &column{name: relKey}
 						switch relVal.(type) {
 						case bool:
@@ -73,11 +75,7 @@ func schemaFromJSON(src io.Reader) (*dbSchema, error) {
 						case float64:
 							rc.typ = ql.Float64
 						case string:
-							if _, ok := toTime(relVal.(string)); ok {
-								rc.typ = ql.Time
-							} else {
-								rc.typ = ql.String
-							}
+							rc.typ = stringType(relVal.(string))
 						case nil:
 							return nil, fmt.Errorf("%s.%v : null objects not supported",
 								k, nk,
@@ -114,6 +112,61 @@ func schemaFromJSON(src io.Reader) (*dbSchema, error) {
 	return s, nil
 }
 
+// jsonKeys returns object keys in document order, indexed by their dotted path.
+// A key is listed once per path, however often it shows up in arrays of objects.
+func jsonKeys(b []byte) (map[string][]string, error) {
+	o := make(map[string][]string)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	return o, walkKeys(dec, "", o, make(map[string]bool))
+}
+
+func walkKeys(dec *json.Decoder, path string, o map[string][]string, seen map[string]bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			kt, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			k := kt.(string)
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if !seen[p] {
+				seen[p] = true
+				o[path] = append(o[path], k)
+			}
+			if err = walkKeys(dec, p, o, seen); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		for dec.More() {
+			if err = walkKeys(dec, path, o, seen); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+func stringType(src string) ql.Type {
+	if _, ok := toTime(src); ok {
+		return ql.Time
+	}
+	if _, _, ok := parseDataURI(src); ok {
+		return ql.Blob
+	}
+	return ql.String
+}
+
 func toTime(src string) (time.Time, bool) {
 	t, err := time.Parse(time.ANSIC, src)
 	if err != nil {
@@ -132,14 +185,32 @@ func schemaFromDBInfo(i *ql.DbInfo) (*dbSchema, error) {
 		tb.i = &v
 		for _, cols := range v.Columns {
 			c := &column{
-				name: cols.Name,
-				i:    &cols,
-				typ:  cols.Type,
+				name:       cols.Name,
+				i:          &cols,
+				typ:        cols.Type,
+				notNull:    cols.NotNull,
+				constraint: cols.Constraint,
+			}
+			if cols.Default != "" {
+				c.defaultValue = cols.Default
 			}
 			tb.columns = append(tb.columns, c)
+			if c.name == deletedAt && c.typ == ql.Time {
+				tb.softDelete = true
+			}
 		}
 		s.tables[tb.name] = tb
 	}
+	for _, v := range i.Indices {
+		if tb, ok := s.tables[v.Table]; ok {
+			tb.indices = append(tb.indices, v)
+		}
+	}
+	for _, tb := range s.tables {
+		sort.Slice(tb.indices, func(a, b int) bool {
+			return tb.indices[a].Name < tb.indices[b].Name
+		})
+	}
 	return buildRelation(s), nil
 }
 
@@ -210,7 +281,8 @@ func (d *dbSchema) migration(i int) (sql string) {
 	}
 	sort.Sort(tbs)
 	for _, v := range tbs {
-		sort.Sort(v.columns)
+		// stable keeps the sample order of columns with the same type.
+		sort.Stable(v.columns)
 		sql += fmt.Sprintln(v.migration(i + 2))
 	}
 	sql += fmt.Sprintln("commit;")
@@ -225,11 +297,13 @@ type table struct {
 	hasMany    *relation
 	manyToMany *relation
 	columns    columnList
+	indices    []ql.IndexInfo
+	softDelete bool
 }
 
 func (t *table) prepare() {
 	var hasID bool
-	for i := 0; i < len(t.columns)-1; i++ {
+	for i := 0; i < len(t.columns); i++ {
 		c := t.columns[i]
 		if c.name == "id" {
 			hasID = true
@@ -255,10 +329,26 @@ func (t *table) migration(i int) (sql string) {
 		} else {
 			fmt.Fprintf(w, ",\n%s%s\t%s", indent(i+2), v.name, v.typ)
 		}
+		switch {
+		case v.notNull:
+			fmt.Fprint(w, " not null")
+		case v.constraint != "":
+			fmt.Fprint(w, " ", v.constraint)
+		}
+		if v.defaultValue != nil {
+			fmt.Fprint(w, " default ", v.defaultValue)
+		}
 	}
 	_ = w.Flush()
 	sql += buf.String()
 	sql += fmt.Sprint(");")
+	for _, v := range t.indices {
+		u := ""
+		if v.Unique {
+			u = "unique "
+		}
+		sql += fmt.Sprintf("\n%s create %sindex %s on %s (%s);", indent(i), u, v.Name, t.name, strings.Join(v.ExpressionList, ", "))
+	}
 	return
 }
 
@@ -284,6 +374,8 @@ type column struct {
 	typ          ql.Type
 	val          reflect.Value
 	defaultValue interface{}
+	notNull      bool
+	constraint   string
 }
 
 type columnList []*column
@@ -307,6 +399,29 @@ type relation struct {
 	destTable string
 }
 
+type manyRelation struct {
+	table *table
+	fk    string
+}
+
+func (c *crud) hasMany(t *table) []manyRelation {
+	var o []manyRelation
+	seen := make(map[string]bool)
+	if t.hasMany != nil {
+		if child, ok := c.schema.tables[t.hasMany.destTable]; ok {
+			seen[child.name] = true
+			o = append(o, manyRelation{table: child, fk: t.hasMany.srcCol})
+		}
+	}
+	for _, v := range c.models() {
+		if v.hasOne != nil && v.hasOne.destTable == t.name && !seen[v.name] {
+			seen[v.name] = true
+			o = append(o, manyRelation{table: v, fk: v.hasOne.srcCol})
+		}
+	}
+	return o
+}
+
 // This is the only comment in this project.
 //
 // Thank you for taking your time to read this code base. I wanted to write a working application
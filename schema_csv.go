@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cznic/ql"
+)
+
+const defaultCSVSample = 100
+
+func schemaFromCSV(model string, src io.Reader, sample int) (*dbSchema, error) {
+	if model == "" {
+		return nil, errors.New("a model name is required for csv schemas")
+	}
+	if sample <= 0 {
+		sample = defaultCSVSample
+	}
+	r := csv.NewReader(src)
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %v", err)
+	}
+	values := make([][]string, len(header))
+	for n := 0; n < sample; n++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range rec {
+			values[k] = append(values[k], v)
+		}
+	}
+	t := &table{name: tableName(model)}
+	for k, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("%s: column %d has no name", model, k+1)
+		}
+		if _, ok := t.colID(name); ok {
+			return nil, fmt.Errorf("%s.%s : duplicate column", model, name)
+		}
+		t.columns = append(t.columns, &column{
+			name: name,
+			typ:  inferType(values[k]),
+		})
+	}
+	if idx, ok := t.colID("id"); ok && t.columns[idx].typ != ql.Int64 {
+		return nil, fmt.Errorf("%s.id : expected integer values", model)
+	}
+	s := &dbSchema{tables: map[string]*table{t.name: t}}
+	err = s.prepareRelations()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// inferType picks the narrowest type that every non empty value satisfies.
+func inferType(values []string) ql.Type {
+	is := map[ql.Type]bool{
+		ql.Int64:   true,
+		ql.Float64: true,
+		ql.Bool:    true,
+		ql.Time:    true,
+	}
+	var seen bool
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		seen = true
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			is[ql.Int64] = false
+		}
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			is[ql.Float64] = false
+		}
+		if l := strings.ToLower(v); l != "true" && l != "false" {
+			is[ql.Bool] = false
+		}
+		if _, ok := parseTime(v); !ok {
+			is[ql.Time] = false
+		}
+	}
+	if !seen {
+		return ql.String
+	}
+	for _, typ := range []ql.Type{ql.Bool, ql.Int64, ql.Float64, ql.Time} {
+		if is[typ] {
+			return typ
+		}
+	}
+	return ql.String
+}
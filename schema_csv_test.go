@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cznic/ql"
+)
+
+func TestInferType(t *testing.T) {
+	sample := []struct {
+		values []string
+		typ    ql.Type
+	}{
+		{[]string{"1", "2", ""}, ql.Int64},
+		{[]string{"1", "2.5"}, ql.Float64},
+		{[]string{"true", "False"}, ql.Bool},
+		{[]string{"Mon Jan 2 15:04:05 2006", "2006-01-02T15:04:05Z"}, ql.Time},
+		{[]string{"1", "one"}, ql.String},
+		{[]string{"", ""}, ql.String},
+	}
+	for _, v := range sample {
+		if typ := inferType(v.values); typ != v.typ {
+			t.Errorf("%v: expected %s got %s", v.values, v.typ, typ)
+		}
+	}
+}
+
+func TestSchemaFromCSV(t *testing.T) {
+	src := `username,email,score,active,created_at
+gernest,gernest@example.com,1.5,true,Mon Jan 2 15:04:05 2006
+geofrey,,2,false,Mon Jan 2 15:04:05 2006
+`
+	s, err := schemaFromCSV("user", strings.NewReader(src), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := schemaFromJSON(strings.NewReader(`
+{
+   "user":{
+      "username":"gernest",
+      "email":"gernest@example.com",
+      "score":1.5,
+      "active":true,
+      "created_at":"Mon Jan 2 15:04:05 2006"
+   }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := js.migration(0)
+	if v := s.migration(0); v != expect {
+		t.Errorf("expected %s got %s", expect, v)
+	}
+	if _, err = schemaFromCSV("", strings.NewReader(src), 0); err == nil {
+		t.Error("expected an error without a model name")
+	}
+	if _, err = schemaFromCSV("user", strings.NewReader("id,name\nx,y\n"), 0); err == nil {
+		t.Error("expected an error for non integer ids")
+	}
+	s, err = schemaFromCSV("user", strings.NewReader("name,id\ngernest,1\n"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.tables["users"].columns); n != 2 {
+		t.Errorf("expected the trailing id column to be kept got %d columns", n)
+	}
+}
+
+func TestAPI_csvSchema(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	src := "username,age\ngernest,30\ngeofrey,31\n"
+	r := httptest.NewRequest("POST", "/schema?model=user&import=true", strings.NewReader(src))
+	r.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	o := &importResult{}
+	_ = json.Unmarshal(w.Body.Bytes(), o)
+	if o.Inserted != 2 {
+		t.Errorf("expected 2 got %d", o.Inserted)
+	}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/users/_count?age=30", nil))
+	if !strings.Contains(w.Body.String(), `"count":1`) {
+		t.Errorf("expected one user aged 30 got %s", w.Body)
+	}
+}
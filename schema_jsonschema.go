@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cznic/ql"
+	"github.com/jinzhu/inflection"
+)
+
+const (
+	formatJSONSchema = "jsonschema"
+	jsonSchemaDraft  = "https://json-schema.org/draft/2020-12/schema"
+	jsonSchemaRefs   = "#/$defs/"
+)
+
+type jsonSchema struct {
+	Schema          string                 `json:"$schema,omitempty"`
+	ID              string                 `json:"$id,omitempty"`
+	Ref             string                 `json:"$ref,omitempty"`
+	Title           string                 `json:"title,omitempty"`
+	Description     string                 `json:"description,omitempty"`
+	Type            interface{}            `json:"type,omitempty"`
+	Format          string                 `json:"format,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	ReadOnly        bool                   `json:"readOnly,omitempty"`
+	Properties      map[string]*jsonSchema `json:"properties,omitempty"`
+	Required        []string               `json:"required,omitempty"`
+	Items           *jsonSchema            `json:"items,omitempty"`
+	Defs            map[string]*jsonSchema `json:"$defs,omitempty"`
+	Definitions     map[string]*jsonSchema `json:"definitions,omitempty"`
+}
+
+func (j *jsonSchema) typeName() string {
+	switch t := j.Type.(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if j.Properties != nil {
+		return "object"
+	}
+	if j.Items != nil {
+		return "array"
+	}
+	return ""
+}
+
+func isJSONSchema(b []byte) bool {
+	var o map[string]json.RawMessage
+	if err := json.Unmarshal(b, &o); err != nil {
+		return false
+	}
+	_, ok := o["$schema"]
+	return ok
+}
+
+type jsonSchemaBuilder struct {
+	root *jsonSchema
+	keys map[string][]string
+	s    *dbSchema
+}
+
+func schemaFromJSONSchema(src io.Reader) (*dbSchema, error) {
+	b, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	root := &jsonSchema{}
+	err = json.Unmarshal(b, root)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jsonKeys(b)
+	if err != nil {
+		return nil, err
+	}
+	j := &jsonSchemaBuilder{
+		root: root,
+		keys: keys,
+		s:    &dbSchema{tables: make(map[string]*table)},
+	}
+	var scalar bool
+	for _, k := range j.propertyKeys("", root) {
+		p, path, err := j.resolve(root.Properties[k], joinPath("properties", k))
+		if err != nil {
+			return nil, err
+		}
+		if p.typeName() != "object" {
+			scalar = true
+			continue
+		}
+		_, err = j.model(modelName(k, root.Properties[k]), path, p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, defs := range []string{"$defs", "definitions"} {
+		for _, k := range j.keys[defs] {
+			p := j.defs(defs)[k]
+			if p.typeName() != "object" {
+				continue
+			}
+			_, err = j.model(k, joinPath(defs, k), p)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if scalar {
+		if root.Title == "" {
+			return nil, errors.New("a title is required when the root schema describes a single model")
+		}
+		j.s = &dbSchema{tables: make(map[string]*table)}
+		_, err = j.model(root.Title, "", root)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(j.s.tables) == 0 {
+		return nil, errors.New("json schema has no object definitions")
+	}
+	err = j.s.prepareRelations()
+	if err != nil {
+		return nil, err
+	}
+	return j.s, nil
+}
+
+func (j *jsonSchemaBuilder) defs(name string) map[string]*jsonSchema {
+	if name == "$defs" {
+		return j.root.Defs
+	}
+	return j.root.Definitions
+}
+
+func (j *jsonSchemaBuilder) resolve(s *jsonSchema, path string) (*jsonSchema, string, error) {
+	seen := make(map[string]bool)
+	for s != nil && s.Ref != "" {
+		if seen[s.Ref] {
+			return nil, "", fmt.Errorf("%s: circular $ref", s.Ref)
+		}
+		seen[s.Ref] = true
+		ref := s.Ref
+		var defs string
+		switch {
+		case strings.HasPrefix(ref, "#/$defs/"):
+			defs = "$defs"
+		case strings.HasPrefix(ref, "#/definitions/"):
+			defs = "definitions"
+		default:
+			return nil, "", fmt.Errorf("%s: only local $ref are supported", ref)
+		}
+		name := ref[strings.LastIndexByte(ref, '/')+1:]
+		d, ok := j.defs(defs)[name]
+		if !ok {
+			return nil, "", fmt.Errorf("%s: missing definition", ref)
+		}
+		s, path = d, joinPath(defs, name)
+	}
+	if s == nil {
+		return nil, "", fmt.Errorf("%s: empty schema", path)
+	}
+	return s, path, nil
+}
+
+func (j *jsonSchemaBuilder) propertyKeys(path string, s *jsonSchema) []string {
+	if k, ok := j.keys[joinPath(path, "properties")]; ok {
+		return k
+	}
+	var o []string
+	for k := range s.Properties {
+		o = append(o, k)
+	}
+	sort.Strings(o)
+	return o
+}
+
+func (j *jsonSchemaBuilder) model(name, path string, s *jsonSchema) (*table, error) {
+	tn := tableName(name)
+	if t, ok := j.s.tables[tn]; ok {
+		return t, nil
+	}
+	t := &table{name: tn}
+	j.s.tables[tn] = t
+	required := make(map[string]bool)
+	for _, v := range s.Required {
+		required[v] = true
+	}
+	for _, k := range j.propertyKeys(path, s) {
+		raw := s.Properties[k]
+		p, ppath, err := j.resolve(raw, joinPath(path, "properties", k))
+		if err != nil {
+			return nil, err
+		}
+		switch p.typeName() {
+		case "object":
+			rt, err := j.model(modelName(k, raw), ppath, p)
+			if err != nil {
+				return nil, err
+			}
+			fk := rt.name + "_id"
+			if _, ok := t.colID(fk); !ok {
+				t.columns = append(t.columns, &column{name: fk, typ: ql.Int64, notNull: required[k]})
+			}
+			if t.hasOne == nil {
+				t.hasOne = &relation{srcCol: fk, destTable: rt.name}
+			}
+			t.related = true
+		case "array":
+			items, ipath, err := j.resolve(p.Items, joinPath(ppath, "items"))
+			if err != nil {
+				return nil, err
+			}
+			if items.typeName() != "object" {
+				return nil, fmt.Errorf("%s.%s : arrays of %s are not supported", name, k, items.typeName())
+			}
+			child, err := j.model(modelName(inflection.Singular(k), p.Items), ipath, items)
+			if err != nil {
+				return nil, err
+			}
+			fk := t.name + "_id"
+			if _, ok := child.colID(fk); !ok {
+				child.columns = append(child.columns, &column{name: fk, typ: ql.Int64})
+			}
+			t.hasMany = &relation{srcCol: fk, destTable: child.name}
+		default:
+			typ, err := jsonSchemaType(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s : %v", name, k, err)
+			}
+			t.columns = append(t.columns, &column{name: k, typ: typ, notNull: required[k]})
+		}
+	}
+	return t, nil
+}
+
+func jsonSchemaType(s *jsonSchema) (ql.Type, error) {
+	switch s.typeName() {
+	case "boolean":
+		return ql.Bool, nil
+	case "integer":
+		if s.Format == "duration" {
+			return ql.Duration, nil
+		}
+		return ql.Int64, nil
+	case "number":
+		return ql.Float64, nil
+	case "string":
+		switch s.Format {
+		case "date-time", "date":
+			return ql.Time, nil
+		case "byte", "binary":
+			return ql.Blob, nil
+		case "duration":
+			return ql.Duration, nil
+		}
+		if s.ContentEncoding == "base64" {
+			return ql.Blob, nil
+		}
+		return ql.String, nil
+	}
+	return 0, fmt.Errorf("unsupported type %q", s.typeName())
+}
+
+func modelName(key string, s *jsonSchema) string {
+	if s != nil && s.Ref != "" {
+		return s.Ref[strings.LastIndexByte(s.Ref, '/')+1:]
+	}
+	return key
+}
+
+func joinPath(p ...string) string {
+	var o []string
+	for _, v := range p {
+		if v != "" {
+			o = append(o, v)
+		}
+	}
+	return strings.Join(o, ".")
+}
+
+func (c *crud) modelJSONSchema(model string) (*jsonSchema, error) {
+	t, ok := c.schema.tables[model]
+	if !ok {
+		return nil, fmt.Errorf("model %s not found", model)
+	}
+	o := c.exportJSONSchema(t)
+	o.Schema = jsonSchemaDraft
+	next := c.relatedTables(t)
+	for len(next) > 0 {
+		dt := next[0]
+		next = next[1:]
+		if dt == t {
+			continue
+		}
+		if _, ok := o.Defs[dt.name]; ok {
+			continue
+		}
+		if o.Defs == nil {
+			o.Defs = make(map[string]*jsonSchema)
+		}
+		o.Defs[dt.name] = c.exportJSONSchema(dt)
+		next = append(next, c.relatedTables(dt)...)
+	}
+	return o, nil
+}
+
+func (c *crud) modelsJSONSchema() *jsonSchema {
+	o := &jsonSchema{
+		Schema: jsonSchemaDraft,
+		Defs:   make(map[string]*jsonSchema),
+	}
+	for _, t := range c.models() {
+		o.Defs[t.name] = c.exportJSONSchema(t)
+	}
+	return o
+}
+
+func (c *crud) relatedTables(t *table) []*table {
+	var o []*table
+	if t.hasOne != nil {
+		if dt, ok := c.schema.tables[t.hasOne.destTable]; ok {
+			o = append(o, dt)
+		}
+	}
+	for _, r := range c.hasMany(t) {
+		o = append(o, r.table)
+	}
+	return o
+}
+
+// exportJSONSchema is tableJSONSchema with the has many side of the relations
+// as read only arrays, the api never returns them but importing the schema
+// back needs them.
+func (c *crud) exportJSONSchema(t *table) *jsonSchema {
+	o := tableJSONSchema(t, jsonSchemaRefs)
+	for _, r := range c.hasMany(t) {
+		if _, ok := o.Properties[r.table.name]; ok {
+			continue
+		}
+		o.Properties[r.table.name] = &jsonSchema{
+			Type:     "array",
+			ReadOnly: true,
+			Items:    &jsonSchema{Ref: jsonSchemaRefs + r.table.name},
+		}
+	}
+	return o
+}
+
+func tableJSONSchema(t *table, refs string) *jsonSchema {
+	o := &jsonSchema{
+		Title:      inflection.Singular(t.name),
+		Type:       "object",
+		Properties: make(map[string]*jsonSchema),
+	}
+	for _, col := range t.columns {
+		p := columnJSONSchema(col)
+		switch {
+		case col.notNull:
+			o.Required = append(o.Required, col.name)
+		case col.name != "id":
+			p.Type = []string{p.Type.(string), "null"}
+		}
+		if col.name == "id" || col.name == deletedAt {
+			p.ReadOnly = true
+		}
+		o.Properties[col.name] = p
+	}
+	if t.hasOne != nil {
+		o.Properties[inflection.Singular(t.hasOne.destTable)] = &jsonSchema{
+			Ref: refs + t.hasOne.destTable,
+		}
+	}
+	return o
+}
+
+func columnJSONSchema(col *column) *jsonSchema {
+	switch col.typ {
+	case ql.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case ql.Int8, ql.Int16, ql.Int32, ql.Int64,
+		ql.Uint8, ql.Uint16, ql.Uint32, ql.Uint64, ql.BigInt:
+		return &jsonSchema{Type: "integer"}
+	case ql.Float32, ql.Float64:
+		return &jsonSchema{Type: "number"}
+	case ql.Time:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case ql.Duration:
+		return &jsonSchema{Type: "integer", Format: "duration", Description: "nanoseconds"}
+	case ql.Blob:
+		return &jsonSchema{Type: "string", ContentEncoding: "base64"}
+	}
+	return &jsonSchema{Type: "string"}
+}
+
+func writeJSONSchema(w http.ResponseWriter, s *jsonSchema) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		jsonErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(b)
+}
+
+func (c *crud) jsonSchemaHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := c.modelJSONSchema(model)
+		if err != nil {
+			jsonErr(w, err, http.StatusNotFound)
+			return
+		}
+		writeJSONSchema(w, s)
+	}
+}
+
+func (c *crud) jsonSchemasHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSONSchema(w, c.modelsJSONSchema())
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cznic/ql"
+)
+
+func TestSchemaFromJSONSchema(t *testing.T) {
+	src := `
+{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "properties": {
+    "user": {
+      "type": "object",
+      "required": ["username"],
+      "properties": {
+        "username": {"type": "string"},
+        "age": {"type": ["integer", "null"]},
+        "score": {"type": "number"},
+        "active": {"type": "boolean"},
+        "created_at": {"type": "string", "format": "date-time"},
+        "avatar": {"type": "string", "contentEncoding": "base64"},
+        "profile": {"$ref": "#/$defs/profile"},
+        "posts": {"type": "array", "items": {"type": "object", "properties": {"title": {"type": "string"}}}}
+      }
+    }
+  },
+  "$defs": {
+    "profile": {
+      "type": "object",
+      "properties": {
+        "bio": {"type": "string"}
+      }
+    }
+  }
+}`
+	s, err := schemaFromJSONSchema(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, ok := s.tables["users"]
+	if !ok {
+		t.Fatalf("expected users table got %v", s.tables)
+	}
+	expect := map[string]ql.Type{
+		"username":    ql.String,
+		"age":         ql.Int64,
+		"score":       ql.Float64,
+		"active":      ql.Bool,
+		"created_at":  ql.Time,
+		"avatar":      ql.Blob,
+		"profiles_id": ql.Int64,
+		"id":          ql.Int64,
+	}
+	for name, typ := range expect {
+		idx, ok := users.colID(name)
+		if !ok {
+			t.Errorf("expected column %s", name)
+			continue
+		}
+		if users.columns[idx].typ != typ {
+			t.Errorf("%s: expected %s got %s", name, typ, users.columns[idx].typ)
+		}
+	}
+	if idx, _ := users.colID("username"); !users.columns[idx].notNull {
+		t.Error("expected username to be not null")
+	}
+	if users.hasOne == nil || users.hasOne.destTable != "profiles" {
+		t.Errorf("expected users to have one profile got %v", users.hasOne)
+	}
+	posts, ok := s.tables["posts"]
+	if !ok {
+		t.Fatal("expected posts table")
+	}
+	if _, ok := posts.colID("users_id"); !ok {
+		t.Error("expected posts to reference users")
+	}
+	if !regexp.MustCompile(`username +string not null`).MatchString(s.migration(0)) {
+		t.Errorf("expected a not null constraint got %s", s.migration(0))
+	}
+}
+
+func TestSchemaFromJSONSchema_single(t *testing.T) {
+	src := `
+{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "user",
+  "type": "object",
+  "properties": {
+    "username": {"type": "string"}
+  }
+}`
+	s, err := schemaFromJSONSchema(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.tables["users"]; !ok {
+		t.Errorf("expected users table got %v", s.tables)
+	}
+	bad := []string{
+		`{"$schema":"", "properties":{"name":{"type":"string"}}}`,
+		`{"$schema":"", "properties":{"user":{"properties":{"tags":{"type":"array","items":{"type":"string"}}}}}}`,
+		`{"$schema":"", "properties":{"user":{"$ref":"http://example.com/user.json"}}}`,
+	}
+	for _, v := range bad {
+		if _, err = schemaFromJSONSchema(strings.NewReader(v)); err == nil {
+			t.Errorf("expected an error for %s", v)
+		}
+	}
+}
+
+func TestAPI_jsonSchema(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	src := `{"$schema":"","title":"user","required":["username"],"properties":{"username":{"type":"string"},"age":{"type":"integer"}}}`
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/schema", strings.NewReader(src)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	idx, ok := a.c.schema.tables["users"].colID("username")
+	if !ok || !a.c.schema.tables["users"].columns[idx].notNull {
+		t.Error("expected username to be loaded back as not null")
+	}
+}
+
+func TestAPI_exportJSONSchema(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	r := httptest.NewRequest("POST", "/schema", strings.NewReader(ddlSample+`
+create table posts (
+	title     string,
+	read_time duration,
+	users_id  int64,
+);
+`))
+	r.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/profiles/_schema", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/schema+json" {
+		t.Errorf("expected application/schema+json got %s", ct)
+	}
+	o := &jsonSchema{}
+	if err := json.Unmarshal(w.Body.Bytes(), o); err != nil {
+		t.Fatal(err)
+	}
+	if p := o.Properties["user"]; p == nil || p.Ref != "#/$defs/users" {
+		t.Errorf("expected user to reference users got %v", p)
+	}
+	users, ok := o.Defs["users"]
+	if !ok {
+		t.Fatalf("expected users definition got %v", o.Defs)
+	}
+	if len(users.Required) != 1 || users.Required[0] != "username" {
+		t.Errorf("expected username to be required got %v", users.Required)
+	}
+	if p := users.Properties["age"]; p == nil || p.typeName() != "integer" {
+		t.Errorf("expected age to be an integer got %v", p)
+	}
+
+	if p := users.Properties["posts"]; p == nil || p.typeName() != "array" || !p.ReadOnly || p.Items.Ref != "#/$defs/posts" {
+		t.Errorf("expected users to list their posts got %v", p)
+	}
+	posts, ok := o.Defs["posts"]
+	if !ok {
+		t.Fatalf("expected posts definition got %v", o.Defs)
+	}
+	if p := posts.Properties["read_time"]; p == nil || p.typeName() != "integer" {
+		t.Errorf("expected read_time to be integer nanoseconds got %v", p)
+	}
+
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("GET", "/v1/_schemas", nil))
+	s, err := schemaFromJSONSchema(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"users", "profiles", "posts"} {
+		if _, ok := s.tables[name]; !ok {
+			t.Errorf("expected %s to survive the round trip got %v", name, s.tables)
+		}
+	}
+	if idx, ok := s.tables["posts"].colID("read_time"); !ok || s.tables["posts"].columns[idx].typ != ql.Duration {
+		t.Errorf("expected read_time to come back as a duration got %v", s.tables["posts"].columns)
+	}
+	if u := s.tables["users"]; u.hasMany == nil {
+		t.Error("expected users to have many records again")
+	}
+	if p := s.tables["profiles"]; p.hasOne == nil || p.hasOne.destTable != "users" {
+		t.Errorf("expected profiles to have one user got %v", p.hasOne)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cznic/ql"
+)
+
+const formatQL = "ql"
+
+var ddlStatements = []string{
+	"BEGIN TRANSACTION",
+	"COMMIT",
+	"CREATE TABLE ",
+	"CREATE INDEX ",
+	"CREATE UNIQUE INDEX ",
+}
+
+// compileDDL compiles src and makes sure it only creates tables and indices.
+// The statements are tried on an in memory database so that mistakes are
+// reported before touching the real one.
+func compileDDL(src string) (ql.List, error) {
+	l, err := ql.Compile(src)
+	if err != nil {
+		return ql.List{}, err
+	}
+	var tables int
+	for _, v := range strings.Split(l.String(), "\n") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !isDDL(v) {
+			return ql.List{}, fmt.Errorf("%s : only create table and create index statements are allowed", v)
+		}
+		if strings.HasPrefix(v, "CREATE TABLE ") {
+			name := strings.TrimPrefix(strings.TrimPrefix(v, "CREATE TABLE "), "IF NOT EXISTS ")
+			if special(name) {
+				return ql.List{}, fmt.Errorf("%s : table names starting with __ are reserved", strings.Fields(name)[0])
+			}
+			tables++
+		}
+	}
+	if tables == 0 {
+		return ql.List{}, errors.New("schema has no create table statements")
+	}
+	l, err = ql.Compile("BEGIN TRANSACTION;\n" + l.String() + "COMMIT;")
+	if err != nil {
+		return ql.List{}, err
+	}
+	db, err := ql.OpenMem()
+	if err != nil {
+		return ql.List{}, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	err = runDDL(db, l)
+	if err != nil {
+		return ql.List{}, err
+	}
+	i, err := db.Info()
+	if err != nil {
+		return ql.List{}, err
+	}
+	for _, t := range i.Tables {
+		for _, c := range t.Columns {
+			if c.Type == ql.Complex64 || c.Type == ql.Complex128 {
+				return ql.List{}, fmt.Errorf("%s.%s : %s columns are not supported, json has no complex numbers", t.Name, c.Name, c.Type)
+			}
+		}
+	}
+	return l, nil
+}
+
+func isDDL(stmt string) bool {
+	for _, v := range ddlStatements {
+		if strings.HasPrefix(stmt, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func runDDL(db *ql.DB, l ql.List) error {
+	_, _, err := db.Execute(ql.NewRWCtx(), l)
+	return err
+}
+
+func (a *api) loadSchemaFile(name string) error {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	l, err := compileDDL(string(b))
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	err = runDDL(a.db, l)
+	if err != nil {
+		return err
+	}
+	c, err := newCrud(a.db)
+	if err != nil {
+		return err
+	}
+	a.c = c
+	return a.load()
+}
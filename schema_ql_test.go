@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const ddlSample = `
+create table users (
+	username string not null,
+	age      int64 age >= 0 default 18,
+	email    string,
+);
+create unique index users_email on users (email);
+create table profiles (
+	bio      string,
+	users_id int64,
+);
+`
+
+func TestCompileDDL(t *testing.T) {
+	if _, err := compileDDL(ddlSample); err != nil {
+		t.Fatal(err)
+	}
+	bad := []string{
+		"create table users (name string); insert into users values(\"x\");",
+		"create index users_name on users (name);",
+		"create table users (name string); create table users (name string);",
+		"select 1;",
+		"create table __users (name string);",
+		"create table users (",
+		"create table points (at complex128);",
+	}
+	for _, v := range bad {
+		if _, err := compileDDL(v); err == nil {
+			t.Errorf("expected an error for %s", v)
+		}
+	}
+}
+
+func TestAPI_qlSchema(t *testing.T) {
+	a, done := testAPI(t)
+	defer done()
+	post := func(src string) {
+		r := httptest.NewRequest("POST", "/schema", strings.NewReader(src))
+		r.Header.Set("Content-Type", "text/plain")
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+		}
+	}
+	get := func() string {
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest("GET", "/schema", nil))
+		return w.Body.String()
+	}
+	post(ddlSample)
+	first := get()
+	for _, v := range []string{"not null", "age >= 0 default 18", "create unique index users_email on users (email);"} {
+		if !strings.Contains(first, v) {
+			t.Errorf("expected %q in %s", v, first)
+		}
+	}
+	if p := a.c.schema.tables["profiles"]; p.hasOne == nil || p.hasOne.destTable != "users" {
+		t.Errorf("expected profiles to have one user got %v", p.hasOne)
+	}
+	post(first)
+	if second := get(); second != first {
+		t.Errorf("expected schema to round trip got %s want %s", second, first)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"io/ioutil"
+	"reflect"
 	"testing"
 
 	"strings"
@@ -74,6 +75,42 @@ commit;
 	}
 }
 
+func TestSchemaFromJSON_order(t *testing.T) {
+	src := `{"user":{"zebra":"a","apple":"b","mango":"c","age":1}}`
+	expect := `begin transaction;
+   create table users (
+    age   float64,
+    id    int64,
+    zebra string,
+    apple string,
+    mango string);
+commit;`
+	for i := 0; i < 20; i++ {
+		s, err := schemaFromJSON(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := strings.TrimSpace(s.migration(0)); v != expect {
+			t.Fatalf("expected %s got %s", expect, v)
+		}
+	}
+}
+
+func TestJSONKeys(t *testing.T) {
+	o, err := jsonKeys([]byte(`{"b":1,"a":[{"y":1,"x":2},{"x":3,"z":4}],"b":2,"c":{"y":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string][]string{
+		"":  {"b", "a", "c"},
+		"a": {"y", "x", "z"},
+		"c": {"y"},
+	}
+	if !reflect.DeepEqual(o, expect) {
+		t.Errorf("expected %v got %v", expect, o)
+	}
+}
+
 func TestSchema_handle_time(t *testing.T) {
 	src := `
 {
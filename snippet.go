@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultSnippetLang = "curl"
+
+type snippet struct {
+	method string
+	url    string
+	body   string
+}
+
+var snippetLangs = map[string]func(*snippet) string{
+	"curl":   curlSnippet,
+	"httpie": httpieSnippet,
+	"wget":   wgetSnippet,
+	"go":     goSnippet,
+	"fetch":  fetchSnippet,
+	"python": pythonSnippet,
+}
+
+func snippetLangNames() []string {
+	var o []string
+	for k := range snippetLangs {
+		o = append(o, k)
+	}
+	sort.Strings(o)
+	return o
+}
+
+func renderSnippet(lang string, s *snippet) (string, error) {
+	if lang == "" {
+		lang = defaultSnippetLang
+	}
+	fn, ok := snippetLangs[lang]
+	if !ok {
+		return "", fmt.Errorf("unknown lang %q, expected one of %s", lang, strings.Join(snippetLangNames(), ", "))
+	}
+	return fn(s), nil
+}
+
+func (a *api) snippetHandler(e endpoint) func(http.ResponseWriter, *http.Request) {
+	prefix := "/snippets"
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		lang := q.Get("lang")
+		q.Del("lang")
+		u := a.baseURL + strings.TrimPrefix(r.URL.Path, prefix)
+		if len(q) > 0 {
+			u += "?" + q.Encode()
+		}
+		s := &snippet{
+			method: strings.ToUpper(e.Method),
+			url:    u,
+			body:   e.Payload,
+		}
+		if r.Body != nil {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				jsonErr(w, err, http.StatusBadRequest)
+				return
+			}
+			if len(bytes.TrimSpace(b)) > 0 {
+				s.body = string(b)
+			}
+		}
+		txt, err := renderSnippet(lang, s)
+		if err != nil {
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+		textOk(w, txt)
+	}
+}
+
+func replaceParams(p string, params []param) string {
+	for _, v := range params {
+		p = strings.Replace(p, ":"+v.Name, url.PathEscape(fmt.Sprint(v.Default)), -1)
+	}
+	return p
+}
+
+// shellQuote wraps src in single quotes, which the shell leaves untouched
+// except for single quotes themselves.
+func shellQuote(src string) string {
+	return "'" + strings.Replace(src, "'", `'\''`, -1) + "'"
+}
+
+// jsonQuote gives a string literal that is valid in json, javascript and
+// python.
+func jsonQuote(src string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(src)
+	return strings.TrimSpace(buf.String())
+}
+
+func (s *snippet) hasBody() bool {
+	return s.body != "" && s.method != "GET" && s.method != "DELETE"
+}
+
+func curlSnippet(s *snippet) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "curl -i -X %s %s", s.method, shellQuote(s.url))
+	if s.hasBody() {
+		fmt.Fprintf(&buf, " \\\n  -H 'Content-Type: application/json' \\\n  -d %s", shellQuote(s.body))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+func httpieSnippet(s *snippet) string {
+	if s.hasBody() {
+		return fmt.Sprintf("printf '%%s' %s | http %s %s Content-Type:application/json\n",
+			shellQuote(s.body), s.method, shellQuote(s.url))
+	}
+	return fmt.Sprintf("http %s %s\n", s.method, shellQuote(s.url))
+}
+
+func wgetSnippet(s *snippet) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "wget -q -O - --method=%s", s.method)
+	if s.hasBody() {
+		fmt.Fprintf(&buf, " \\\n  --header='Content-Type: application/json' \\\n  --body-data=%s", shellQuote(s.body))
+	}
+	fmt.Fprintf(&buf, " \\\n  %s\n", shellQuote(s.url))
+	return buf.String()
+}
+
+const goSnippetTpl = `package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"%s
+)
+
+func main() {
+	req, err := http.NewRequest(%s, %s, %s)
+	if err != nil {
+		panic(err)
+	}%s
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(res.Status)
+	fmt.Println(string(b))
+}
+`
+
+func goSnippet(s *snippet) string {
+	imports, body, header := "", "nil", ""
+	if s.hasBody() {
+		imports = "\n\t\"strings\""
+		body = "strings.NewReader(" + strconv.Quote(s.body) + ")"
+		header = "\n\treq.Header.Set(\"Content-Type\", \"application/json\")"
+	}
+	return fmt.Sprintf(goSnippetTpl, imports, strconv.Quote(s.method), strconv.Quote(s.url), body, header)
+}
+
+func fetchSnippet(s *snippet) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "fetch(%s, {\n  method: %s", jsonQuote(s.url), jsonQuote(s.method))
+	if s.hasBody() {
+		body := "JSON.stringify(" + indentJSON(s.body, "  ") + ")"
+		if !json.Valid([]byte(s.body)) {
+			body = jsonQuote(s.body)
+		}
+		fmt.Fprintf(&buf, ",\n  headers: {\"Content-Type\": \"application/json\"},\n  body: %s", body)
+	}
+	buf.WriteString("\n})\n  .then(res => res.text())\n  .then(console.log);\n")
+	return buf.String()
+}
+
+func pythonSnippet(s *snippet) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "import requests\n\nres = requests.%s(\n    %s,\n", strings.ToLower(s.method), jsonQuote(s.url))
+	if s.hasBody() {
+		fmt.Fprintf(&buf, "    data=%s,\n    headers={\"Content-Type\": \"application/json\"},\n", jsonQuote(s.body))
+	}
+	buf.WriteString(")\nprint(res.status_code, res.text)\n")
+	return buf.String()
+}
+
+func indentJSON(src, prefix string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(src), prefix, "  "); err != nil {
+		return src
+	}
+	return buf.String()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cznic/ql"
+)
+
+const deletedAt = "deleted_at"
+
+func (d *dbSchema) softDelete(models []string) error {
+	for _, m := range models {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		if m == "*" {
+			for _, t := range d.tables {
+				t.addSoftDelete()
+			}
+			continue
+		}
+		t, ok := d.tables[tableName(m)]
+		if !ok {
+			return fmt.Errorf("soft delete: missing model %s", m)
+		}
+		t.addSoftDelete()
+	}
+	return nil
+}
+
+func (t *table) addSoftDelete() {
+	if _, ok := t.colID(deletedAt); !ok {
+		t.columns = append(t.columns, &column{name: deletedAt, typ: ql.Time})
+	}
+	t.softDelete = true
+}
+
+func (c *crud) softDeletes(model string) bool {
+	t, ok := c.schema.tables[model]
+	return ok && t.softDelete
+}
+
+func (c *crud) scopeWhere(model string, scope int) string {
+	if !c.softDeletes(model) {
+		return ""
+	}
+	switch scope {
+	case scopeLive:
+		return deletedAt + " IS NULL"
+	case scopeTrash:
+		return deletedAt + " IS NOT NULL"
+	}
+	return ""
+}
+
+func (c *crud) restore(model string, id int64) (modelProps, error) {
+	var r modelProps
+	err := c.inTx(func(tx *crudTx) error {
+		o, err := c.getByIDCtx(tx.ctx, model, id, scopeTrash)
+		if err != nil {
+			return err
+		}
+		if len(o) == 0 {
+			return errNotFound
+		}
+		r, err = c.updateTx(tx, model, id, modelProps{deletedAt: nil})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (c *crud) trashHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.streamList(w, r, model, &listQuery{scope: scopeTrash})
+	}
+}
+
+func (c *crud) restoreHandler(model string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := idParam(r)
+		if err != nil {
+			jsonErr(w, err, http.StatusBadRequest)
+			return
+		}
+		o, err := c.restore(model, id)
+		if err != nil {
+			jsonErr(w, err, crudErrCode(err))
+			return
+		}
+		jsonRes(w, o)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cznic/ql"
+	"github.com/gernest/alien"
+)
+
+func TestCRUD_softDelete(t *testing.T) {
+	s, err := schemaFromJSON(strings.NewReader(`{"user":{"name":"gernest"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.softDelete([]string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s.migration(0), "deleted_at time") {
+		t.Errorf("expected deleted_at column in migration got %s", s.migration(0))
+	}
+	if err = s.softDelete([]string{"missing"}); err == nil {
+		t.Error("expected an error for missing model")
+	}
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	err = runMigration(db, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.softDeletes("users") {
+		t.Fatal("expected users to be soft deleted")
+	}
+	for _, name := range []string{"a", "b"} {
+		_, err = c.create("users", modelProps{"name": name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = c.deleteByID("users", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(scope int) int {
+		o, err := c.list("users", &listQuery{scope: scope})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(o)
+	}
+	if n := count(scopeLive); n != 1 {
+		t.Errorf("expected 1 live record got %d", n)
+	}
+	if n := count(scopeAll); n != 2 {
+		t.Errorf("expected 2 records got %d", n)
+	}
+	if n := count(scopeTrash); n != 1 {
+		t.Errorf("expected 1 deleted record got %d", n)
+	}
+	if o, _ := c.getByID("users", 1, scopeLive); o != nil {
+		t.Errorf("expected deleted record to be hidden got %v", o)
+	}
+	r := alien.New()
+	_ = r.Get("/users/:id", c.getByIDHandler("users"))
+	get := func(target string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w.Code
+	}
+	if code := get("/users/1"); code != http.StatusNotFound {
+		t.Errorf("expected %d got %d", http.StatusNotFound, code)
+	}
+	if code := get("/users/1?with_deleted=true"); code != http.StatusOK {
+		t.Errorf("expected the deleted record with with_deleted got %d", code)
+	}
+	if err = c.deleteByID("users", 1, ""); err != errNotFound {
+		t.Errorf("expected %v got %v", errNotFound, err)
+	}
+	veto := true
+	var restored modelProps
+	c.before("users", changeUpdate, func(h *hookContext) error {
+		if veto {
+			return abortHook(http.StatusForbidden, "restoring is not allowed")
+		}
+		return nil
+	})
+	c.after("users", changeUpdate, func(h *hookContext) error {
+		restored = h.Props
+		return nil
+	})
+	if _, err = c.restore("users", 1); crudErrCode(err) != http.StatusForbidden {
+		t.Errorf("expected the hook to veto the restore got %v", err)
+	}
+	if n := count(scopeTrash); n != 1 {
+		t.Errorf("expected 1 deleted record got %d", n)
+	}
+	veto = false
+	p, err := c.restore("users", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored["name"] != "a" {
+		t.Errorf("expected the after hook to see the restored record got %v", restored)
+	}
+	if p["deleted_at"] != nil {
+		t.Errorf("expected deleted_at to be cleared got %v", p["deleted_at"])
+	}
+	if n := count(scopeLive); n != 2 {
+		t.Errorf("expected 2 live records got %d", n)
+	}
+	if _, err = c.restore("users", 2); err != errNotFound {
+		t.Errorf("expected %v got %v", errNotFound, err)
+	}
+}
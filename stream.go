@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cznic/ql"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+var formatTypes = map[string]string{
+	formatJSON:   "application/json",
+	formatNDJSON: "application/x-ndjson",
+	formatCSV:    "text/csv; charset=utf-8",
+}
+
+func (c *crud) each(model string, q *listQuery, fn func(names []string, data []interface{}) (bool, error)) error {
+	err := c.beforeRead(model, 0)
+	if err != nil {
+		return err
+	}
+	if c.hooks.get(true, model, hookRead) != nil {
+		next := fn
+		fn = func(names []string, data []interface{}) (bool, error) {
+			names, data, err := c.readRow(model, names, data)
+			if err != nil {
+				return false, err
+			}
+			return next(names, data)
+		}
+	}
+	where, args := c.where(model, q)
+	ctx := make(map[string]interface{})
+	ctx["model"] = model
+	ctx["where"] = where
+	ctx["limit"] = q.limit
+	ctx["offset"] = q.offset
+	var buf bytes.Buffer
+	err = tpl.ExecuteTemplate(&buf, "get_all", ctx)
+	if err != nil {
+		return err
+	}
+	rs, _, err := c.db.Run(ql.NewRWCtx(), buf.String(), args...)
+	if err != nil {
+		return err
+	}
+	for _, v := range rs {
+		names, err := v.Fields()
+		if err != nil {
+			return err
+		}
+		err = v.Do(false, func(data []interface{}) (bool, error) {
+			return fn(names, data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func negotiateFormat(t *table, r *http.Request) string {
+	if f := controlParam(t, r.URL.Query(), "format"); f != "" {
+		if _, ok := formatTypes[f]; ok {
+			return f
+		}
+	}
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		t, _, _ := mime.ParseMediaType(strings.TrimSpace(v))
+		switch t {
+		case "application/json":
+			return formatJSON
+		case "application/x-ndjson", "application/ndjson", "application/jsonlines":
+			return formatNDJSON
+		case "text/csv":
+			return formatCSV
+		}
+	}
+	return formatJSON
+}
+
+type rowWriter interface {
+	header(names []string) error
+	row(names []string, data []interface{}) error
+	close() error
+}
+
+func newRowWriter(format string, w io.Writer) rowWriter {
+	switch format {
+	case formatNDJSON:
+		return &jsonRowWriter{w: w, sep: "\n", end: "\n"}
+	case formatCSV:
+		return &csvRowWriter{w: csv.NewWriter(w)}
+	}
+	return &jsonRowWriter{w: w, begin: "[", sep: ",", end: "]", array: true}
+}
+
+type jsonRowWriter struct {
+	w          io.Writer
+	begin, sep string
+	end        string
+	array      bool
+	n          int
+}
+
+func (j *jsonRowWriter) header(names []string) error {
+	_, err := io.WriteString(j.w, j.begin)
+	return err
+}
+
+func (j *jsonRowWriter) row(names []string, data []interface{}) error {
+	p := make(modelProps)
+	for k, v := range data {
+		p[names[k]] = v
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if j.n > 0 {
+		if _, err = io.WriteString(j.w, j.sep); err != nil {
+			return err
+		}
+	}
+	j.n++
+	_, err = j.w.Write(b)
+	return err
+}
+
+func (j *jsonRowWriter) close() error {
+	if !j.array && j.n == 0 {
+		return nil
+	}
+	_, err := io.WriteString(j.w, j.end)
+	return err
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvRowWriter) header(names []string) error {
+	return c.w.Write(names)
+}
+
+func (c *csvRowWriter) row(names []string, data []interface{}) error {
+	rec := make([]string, len(data))
+	for k, v := range data {
+		rec[k] = csvValue(v)
+	}
+	return c.w.Write(rec)
+}
+
+func (c *csvRowWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func csvValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return base64.StdEncoding.EncodeToString(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// spoolMemory is how much of a list response is held in memory before the
+// rest goes to a temporary file.
+const spoolMemory = 1 << 20
+
+type spool struct {
+	buf bytes.Buffer
+	f   *os.File
+	n   int
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	s.n += len(p)
+	if s.f == nil && s.buf.Len()+len(p) <= spoolMemory {
+		return s.buf.Write(p)
+	}
+	if s.f == nil {
+		f, err := ioutil.TempFile("", "qlfu-list")
+		if err != nil {
+			return 0, err
+		}
+		s.f = f
+		if _, err = s.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+	return s.f.Write(p)
+}
+
+func (s *spool) WriteTo(w io.Writer) (int64, error) {
+	if s.f == nil {
+		return s.buf.WriteTo(w)
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, s.f)
+}
+
+func (s *spool) close() {
+	if s.f != nil {
+		_ = s.f.Close()
+		_ = os.Remove(s.f.Name())
+	}
+}
+
+const streamFlush = 64
+
+// streamList sends the status with the first row, a failure after it aborts
+// the response. Only If-None-Match requests are buffered.
+func (c *crud) streamList(w http.ResponseWriter, r *http.Request, model string, q *listQuery) {
+	if r.Header.Get("If-None-Match") != "" {
+		c.bufferList(w, r, model, q)
+		return
+	}
+	format := negotiateFormat(c.schema.tables[model], r)
+	flusher, _ := w.(http.Flusher)
+	rw := newRowWriter(format, w)
+	var n int
+	err := c.each(model, q, func(names []string, data []interface{}) (bool, error) {
+		if n == 0 {
+			w.Header().Set("Content-Type", formatTypes[format])
+			w.Header().Set("Vary", "Accept")
+			w.WriteHeader(http.StatusOK)
+			if err := rw.header(names); err != nil {
+				return false, err
+			}
+		}
+		n++
+		if err := rw.row(names, data); err != nil {
+			return false, err
+		}
+		if flusher != nil && n%streamFlush == 0 {
+			flusher.Flush()
+		}
+		return true, nil
+	})
+	if err == nil && n > 0 {
+		err = rw.close()
+	}
+	switch {
+	case err != nil && n > 0:
+		panic(http.ErrAbortHandler)
+	case err != nil:
+		jsonErr(w, err, crudErrCode(err))
+	case n == 0:
+		jsonErr(w, errNotFound, http.StatusNotFound)
+	}
+}
+
+// bufferList reads the rows once, hashing them for the ETag while they are
+// written to a spool, and only sends the body when the tag did not match.
+func (c *crud) bufferList(w http.ResponseWriter, r *http.Request, model string, q *listQuery) {
+	format := negotiateFormat(c.schema.tables[model], r)
+	h := sha1.New()
+	hw := newRowWriter(formatJSON, h)
+	body := &spool{}
+	defer body.close()
+	rw := newRowWriter(format, body)
+	var n int
+	err := c.each(model, q, func(names []string, data []interface{}) (bool, error) {
+		if n == 0 {
+			if err := hw.header(names); err != nil {
+				return false, err
+			}
+			if err := rw.header(names); err != nil {
+				return false, err
+			}
+		}
+		n++
+		if err := hw.row(names, data); err != nil {
+			return false, err
+		}
+		return true, rw.row(names, data)
+	})
+	if err == nil {
+		err = hw.close()
+	}
+	if err == nil && n > 0 {
+		err = rw.close()
+	}
+	if err != nil {
+		jsonErr(w, err, crudErrCode(err))
+		return
+	}
+	if n == 0 {
+		jsonErr(w, errNotFound, http.StatusNotFound)
+		return
+	}
+	tag := fmt.Sprintf(`"%x"`, h.Sum(nil))
+	if format != formatJSON {
+		tag = strings.TrimSuffix(tag, `"`) + "-" + format + `"`
+	}
+	w.Header().Set("Vary", "Accept")
+	if notModified(w, r, tag) {
+		return
+	}
+	w.Header().Set("Content-Type", formatTypes[format])
+	w.Header().Set("Content-Length", strconv.Itoa(body.n))
+	w.WriteHeader(http.StatusOK)
+	_, _ = body.WriteTo(w)
+}
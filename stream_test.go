@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/cznic/ql"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	sample := []struct {
+		accept, query, format string
+	}{
+		{"", "", formatJSON},
+		{"text/csv", "", formatCSV},
+		{"application/x-ndjson", "", formatNDJSON},
+		{"text/html, text/csv;q=0.9", "", formatCSV},
+		{"text/csv", "format=ndjson", formatNDJSON},
+	}
+	for _, v := range sample {
+		r := httptest.NewRequest("GET", "/users?"+v.query, nil)
+		r.Header.Set("Accept", v.accept)
+		if f := negotiateFormat(&table{name: "users"}, r); f != v.format {
+			t.Errorf("%s: expected %s got %s", v.accept, v.format, f)
+		}
+	}
+}
+
+func TestCRUD_streamList(t *testing.T) {
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	_, _, err = db.Run(ql.NewRWCtx(), `
+	begin transaction;
+		create table users(
+			id int64,
+			name string,
+		);
+	commit;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.getAllHandler("users")
+	get := func(accept string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/users", nil)
+		r.Header.Set("Accept", accept)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+	if w := get(""); w.Code != http.StatusNotFound {
+		t.Errorf("expected %d got %d", http.StatusNotFound, w.Code)
+	}
+	for _, name := range []string{"a", "b"} {
+		_, err = c.create("users", modelProps{"name": name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := get("application/json")
+	expect := `[{"id":2,"name":"b"},{"id":1,"name":"a"}]`
+	if w.Body.String() != expect {
+		t.Errorf("expected %s got %s", expect, w.Body)
+	}
+	if tag := w.Header().Get("ETag"); tag != "" {
+		t.Errorf("expected no ETag on a streamed list got %s", tag)
+	}
+	w = get("application/json", "If-None-Match", `"stale"`)
+	if w.Body.String() != expect {
+		t.Errorf("expected %s got %s", expect, w.Body)
+	}
+	o, _ := c.getAll("users")
+	if tag := w.Header().Get("ETag"); tag != etag(o) {
+		t.Errorf("expected %s got %s", etag(o), tag)
+	}
+
+	w = get("application/x-ndjson")
+	expect = "{\"id\":2,\"name\":\"b\"}\n{\"id\":1,\"name\":\"a\"}\n"
+	if w.Body.String() != expect {
+		t.Errorf("expected %q got %q", expect, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected application/x-ndjson got %s", ct)
+	}
+
+	w = get("text/csv")
+	expect = "id,name\n2,b\n1,a\n"
+	if w.Body.String() != expect {
+		t.Errorf("expected %q got %q", expect, w.Body)
+	}
+
+	var reads int
+	fail := ""
+	c.after("users", hookRead, func(h *hookContext) error {
+		reads++
+		if h.Props["name"] == fail {
+			return abortHook(http.StatusForbidden, "no access to %s", h.Props["name"])
+		}
+		return nil
+	})
+	if w = get(""); w.Code != http.StatusOK || reads != 2 {
+		t.Errorf("expected a single read of 2 rows got %d reads %d", w.Code, reads)
+	}
+	fail = "b"
+	if w = get(""); w.Code != http.StatusForbidden {
+		t.Errorf("expected a failed first read to be reported got %d %s", w.Code, w.Body)
+	}
+	fail = "a"
+	if w = get("", "If-None-Match", `"stale"`); w.Code != http.StatusForbidden {
+		t.Errorf("expected a failed buffered read to be reported got %d %s", w.Code, w.Body)
+	}
+	func() {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler {
+				t.Errorf("expected a failed read to abort the stream got %v", err)
+			}
+		}()
+		get("")
+	}()
+}
+
+func TestSpool(t *testing.T) {
+	s := &spool{}
+	defer s.close()
+	b := bytes.Repeat([]byte("a"), spoolMemory/2+1)
+	for i := 0; i < 3; i++ {
+		if _, err := s.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.f == nil {
+		t.Fatal("expected the spool to move to a file")
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != s.n || buf.Len() != 3*len(b) {
+		t.Errorf("expected %d bytes got %d", 3*len(b), buf.Len())
+	}
+	name := s.f.Name()
+	s.close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed got %v", name, err)
+	}
+}
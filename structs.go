@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/urfave/cli"
+)
+
+var structsTpl = template.Must(template.New("structs").Parse(structsSrc))
+
+type structsData struct {
+	Package   string
+	Migration string
+	Time      bool
+	Big       bool
+	Models    []*goModel
+}
+
+// structModels is like goModels but the fields carry ql tags and relations
+// are left to the foreign key columns.
+func structModels(c *crud) []*goModel {
+	var o []*goModel
+	for _, t := range c.models() {
+		m := &goModel{
+			Name:  modelTypeName(t.name),
+			Table: t.name,
+		}
+		for _, col := range t.columns {
+			typ := goType(col.typ)
+			if !col.notNull && col.name != "id" && typ[0] != '[' && typ[0] != '*' {
+				typ = "*" + typ
+			}
+			tags := []string{"name " + col.name}
+			for _, idx := range t.indices {
+				if len(idx.ExpressionList) != 1 || idx.ExpressionList[0] != col.name {
+					continue
+				}
+				if idx.Unique {
+					tags = append(tags, "uindex "+idx.Name)
+				} else {
+					tags = append(tags, "index "+idx.Name)
+				}
+			}
+			m.Fields = append(m.Fields, &goField{
+				Name:   goName(col.name),
+				Type:   typ,
+				JSON:   col.name,
+				Column: col.name,
+				QL:     strings.Join(tags, ", "),
+			})
+		}
+		o = append(o, m)
+	}
+	return o
+}
+
+func genStructs(c *crud, pkg string) ([]byte, error) {
+	// migration sorts the columns, so it goes first to keep the struct fields
+	// in the same order as the create table statements.
+	mig := c.schema.migration(0)
+	d := structsData{
+		Package:   pkg,
+		Migration: "`" + mig + "`",
+		Models:    structModels(c),
+	}
+	if strings.Contains(mig, "`") {
+		d.Migration = strconv.Quote(mig)
+	}
+	for _, m := range d.Models {
+		for _, f := range m.Fields {
+			switch f.Type {
+			case "time.Time", "*time.Time", "time.Duration", "*time.Duration":
+				d.Time = true
+			case "*big.Int", "*big.Rat":
+				d.Big = true
+			}
+		}
+	}
+	var buf bytes.Buffer
+	err := structsTpl.Execute(&buf, d)
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func genStructsCommand(ctx *cli.Context) error {
+	c, done, err := genCrud(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	b, err := genStructs(c, genPackage(ctx))
+	if err != nil {
+		return err
+	}
+	return writeGenerated(ctx, "models.go", b)
+}
+
+const structsSrc = `// Code generated by qlfu gen structs, edit it as you see fit.
+
+// Package {{.Package}} has a struct per table of the schema prototyped with
+// qlfu. The ql tags keep the column names so the structs work with
+// ql.StructValues, ql.StructSchema and ql.Schema.
+package {{.Package}}
+{{if or .Time .Big}}
+import (
+{{- if .Big}}
+	"math/big"
+{{- end}}
+{{- if .Time}}
+	"time"
+{{- end}}
+)
+{{end}}
+// Migration creates the tables and indices of the schema.
+const Migration = {{.Migration}}
+{{range .Models}}
+// {{.Name}} is a record of the {{.Table}} table.
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`" + `ql:"{{.QL}}" json:"{{.JSON}}"` + "`" + `
+{{- end}}
+}
+{{end}}`
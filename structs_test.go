@@ -0,0 +1,39 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenStructs(t *testing.T) {
+	c := testCrud(t, ddlSample)
+	b, err := genStructs(c, "models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "models.go", b, 0); err != nil {
+		t.Fatalf("%v\n%s", err, b)
+	}
+	s := strings.Join(strings.Fields(string(b)), " ")
+	for _, v := range []string{
+		"package models",
+		"const Migration = `begin transaction;",
+		"create unique index users_email on users (email);",
+		"type User struct",
+		"Username string `ql:\"name username\" json:\"username\"`",
+		"Email *string `ql:\"name email, uindex users_email\" json:\"email\"`",
+		"UsersID *int64 `ql:\"name users_id\" json:\"users_id\"`",
+	} {
+		if !strings.Contains(s, v) {
+			t.Errorf("expected %s in\n%s", v, s)
+		}
+	}
+	if strings.Contains(s, "import") {
+		t.Error("expected no imports without time or big columns")
+	}
+	if _, err := compileDDL(c.schema.migration(0)); err != nil {
+		t.Errorf("expected the migration to compile: %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cznic/ql"
+)
+
+var timeLayouts = []string{
+	time.ANSIC,
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+type validationError struct {
+	fields map[string]string
+}
+
+func (v *validationError) add(name, msg string) {
+	if v.fields == nil {
+		v.fields = make(map[string]string)
+	}
+	v.fields[name] = msg
+}
+
+func (v *validationError) Error() string {
+	var names []string
+	for k := range v.fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var s []string
+	for _, k := range names {
+		s = append(s, k+": "+v.fields[k])
+	}
+	return "invalid fields " + strings.Join(s, ", ")
+}
+
+func (v *validationError) failed() bool {
+	return len(v.fields) > 0
+}
+
+func decodeProps(b []byte) ([]modelProps, bool, error) {
+	b = bytes.TrimSpace(b)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if len(b) > 0 && b[0] == '[' {
+		var props []modelProps
+		err := dec.Decode(&props)
+		return props, true, err
+	}
+	prop := make(modelProps)
+	err := dec.Decode(&prop)
+	return []modelProps{prop}, false, err
+}
+
+func (c *crud) validate(model string, props modelProps, strict bool) error {
+	v := &validationError{}
+	c.validateProps("", model, props, strict, v)
+	if v.failed() {
+		return v
+	}
+	return nil
+}
+
+func (c *crud) validateProps(prefix, model string, props modelProps, strict bool, v *validationError) {
+	t, ok := c.schema.tables[model]
+	if !ok {
+		v.add(strings.TrimSuffix(prefix, "."), "unknown model "+model)
+		return
+	}
+	for k, val := range props {
+		if idx, ok := t.colID(k); ok {
+			cv, err := coerce(t.columns[idx].typ, val)
+			if err != nil {
+				v.add(prefix+k, err.Error())
+				continue
+			}
+			props[k] = cv
+			continue
+		}
+		if t.hasOne != nil && isRelationProp(t.hasOne.destTable, k) {
+			if one, ok := props.propProperty(k); ok {
+				c.validateProps(prefix+k+".", t.hasOne.destTable, one, strict, v)
+				continue
+			}
+			v.add(prefix+k, "expected an object")
+			continue
+		}
+		if strict {
+			v.add(prefix+k, "unknown field")
+		}
+	}
+}
+
+func coerce(typ ql.Type, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch typ {
+	case ql.Bool:
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case string:
+			b, err := strconv.ParseBool(x)
+			if err != nil {
+				return nil, fmt.Errorf("expected bool got %q", x)
+			}
+			return b, nil
+		}
+	case ql.String:
+		if x, ok := v.(string); ok {
+			return x, nil
+		}
+	case ql.Float32, ql.Float64:
+		f, err := toFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		if typ == ql.Float32 {
+			return float32(f), nil
+		}
+		return f, nil
+	case ql.Int8, ql.Int16, ql.Int32, ql.Int64,
+		ql.Uint8, ql.Uint16, ql.Uint32, ql.Uint64:
+		return toInt(typ, v)
+	case ql.Time:
+		switch x := v.(type) {
+		case time.Time:
+			return x, nil
+		case string:
+			if t, ok := parseTime(x); ok {
+				return t, nil
+			}
+			return nil, fmt.Errorf("expected time got %q", x)
+		}
+	case ql.Duration:
+		switch x := v.(type) {
+		case time.Duration:
+			return x, nil
+		case string:
+			d, err := time.ParseDuration(x)
+			if err != nil {
+				return nil, fmt.Errorf("expected duration got %q", x)
+			}
+			return d, nil
+		case json.Number, float64:
+			n, err := toInt(ql.Int64, x)
+			if err != nil {
+				return nil, err
+			}
+			return time.Duration(n.(int64)), nil
+		}
+	case ql.Blob:
+		switch x := v.(type) {
+		case []byte:
+			return x, nil
+		case string:
+			return decodeBlob(x)
+		}
+	case ql.BigInt:
+		switch x := v.(type) {
+		case *big.Int:
+			return x, nil
+		case json.Number, string:
+			s := strings.TrimSpace(fmt.Sprint(x))
+			if n, ok := new(big.Int).SetString(s, 10); ok {
+				return n, nil
+			}
+			return nil, fmt.Errorf("expected integer got %q", s)
+		case float64:
+			if x != math.Trunc(x) || math.IsInf(x, 0) {
+				return nil, fmt.Errorf("expected integer got %v", x)
+			}
+			n, _ := big.NewFloat(x).Int(nil)
+			return n, nil
+		}
+	case ql.BigRat:
+		switch x := v.(type) {
+		case *big.Rat:
+			return x, nil
+		case json.Number, string:
+			s := strings.TrimSpace(fmt.Sprint(x))
+			if r, ok := new(big.Rat).SetString(s); ok {
+				return r, nil
+			}
+			return nil, fmt.Errorf("expected rational number got %q", s)
+		case float64:
+			if r := new(big.Rat).SetFloat64(x); r != nil {
+				return r, nil
+			}
+			return nil, fmt.Errorf("expected rational number got %v", x)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+	return nil, fmt.Errorf("expected %s got %T", typ, v)
+}
+
+func parseTime(src string) (time.Time, bool) {
+	for _, l := range timeLayouts {
+		if t, err := time.Parse(l, src); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case json.Number:
+		return x.Float64()
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected number got %q", x)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("expected number got %T", v)
+}
+
+func toInt(typ ql.Type, v interface{}) (interface{}, error) {
+	n := new(big.Int)
+	switch x := v.(type) {
+	case int64:
+		n.SetInt64(x)
+	case int:
+		n.SetInt64(int64(x))
+	case uint64:
+		n.SetUint64(x)
+	case json.Number, string:
+		s := strings.TrimSpace(fmt.Sprint(x))
+		if _, ok := n.SetString(s, 10); !ok {
+			f, _, err := big.ParseFloat(s, 10, 256, big.ToNearestEven)
+			if err != nil || !f.IsInt() {
+				return nil, fmt.Errorf("expected integer got %q", s)
+			}
+			f.Int(n)
+		}
+	case float64:
+		if x != math.Trunc(x) || math.IsInf(x, 0) {
+			return nil, fmt.Errorf("expected integer got %v", x)
+		}
+		big.NewFloat(x).Int(n)
+	default:
+		return nil, fmt.Errorf("expected integer got %T", v)
+	}
+	min, max := intRange(typ)
+	if n.Cmp(min) < 0 || n.Cmp(max) > 0 {
+		return nil, fmt.Errorf("%s overflows %s", n, typ)
+	}
+	switch typ {
+	case ql.Int8:
+		return int8(n.Int64()), nil
+	case ql.Int16:
+		return int16(n.Int64()), nil
+	case ql.Int32:
+		return int32(n.Int64()), nil
+	case ql.Uint8:
+		return uint8(n.Uint64()), nil
+	case ql.Uint16:
+		return uint16(n.Uint64()), nil
+	case ql.Uint32:
+		return uint32(n.Uint64()), nil
+	case ql.Uint64:
+		return n.Uint64(), nil
+	}
+	return n.Int64(), nil
+}
+
+func intRange(typ ql.Type) (min, max *big.Int) {
+	switch typ {
+	case ql.Int8:
+		return big.NewInt(math.MinInt8), big.NewInt(math.MaxInt8)
+	case ql.Int16:
+		return big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)
+	case ql.Int32:
+		return big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)
+	case ql.Uint8:
+		return new(big.Int), big.NewInt(math.MaxUint8)
+	case ql.Uint16:
+		return new(big.Int), big.NewInt(math.MaxUint16)
+	case ql.Uint32:
+		return new(big.Int), big.NewInt(math.MaxUint32)
+	case ql.Uint64:
+		return new(big.Int), new(big.Int).SetUint64(math.MaxUint64)
+	}
+	return big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/cznic/ql"
+)
+
+func TestCoerce(t *testing.T) {
+	now := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	sample := []struct {
+		typ    ql.Type
+		src    interface{}
+		expect interface{}
+	}{
+		{ql.Float64, "1.5", 1.5},
+		{ql.Float64, json.Number("2"), float64(2)},
+		{ql.Int64, json.Number("42"), int64(42)},
+		{ql.Int64, float64(3), int64(3)},
+		{ql.Int64, "7", int64(7)},
+		{ql.Int8, "7", int8(7)},
+		{ql.Bool, "true", true},
+		{ql.String, "hello", "hello"},
+		{ql.Time, "Mon Jan 2 15:04:05 2006", now},
+		{ql.Time, "2006-01-02T15:04:05Z", now},
+		{ql.Duration, "1s", time.Second},
+		{ql.String, nil, nil},
+		{ql.Int64, "1e3", int64(1000)},
+		{ql.Uint64, "18446744073709551615", uint64(math.MaxUint64)},
+		{ql.Uint32, float64(4294967295), uint32(math.MaxUint32)},
+	}
+	for _, v := range sample {
+		got, err := coerce(v.typ, v.src)
+		if err != nil {
+			t.Errorf("%s %v: %v", v.typ, v.src, err)
+			continue
+		}
+		if tm, ok := got.(time.Time); ok {
+			if !tm.Equal(v.expect.(time.Time)) {
+				t.Errorf("expected %v got %v", v.expect, got)
+			}
+			continue
+		}
+		if got != v.expect {
+			t.Errorf("expected %#v got %#v", v.expect, got)
+		}
+	}
+	b, err := coerce(ql.Blob, "aGVsbG8=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b.([]byte)) != "hello" {
+		t.Errorf("expected hello got %s", b)
+	}
+	n, err := coerce(ql.BigInt, json.Number("123456789012345678901234567890"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.(*big.Int).String() != "123456789012345678901234567890" {
+		t.Errorf("expected 123456789012345678901234567890 got %v", n)
+	}
+	r, err := coerce(ql.BigRat, "1/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.(*big.Rat).String() != "1/3" {
+		t.Errorf("expected 1/3 got %v", r)
+	}
+	invalid := []struct {
+		typ ql.Type
+		src interface{}
+	}{
+		{ql.Float64, "one"},
+		{ql.Int64, float64(1.5)},
+		{ql.Int8, "300"},
+		{ql.Uint8, "-1"},
+		{ql.Bool, "maybe"},
+		{ql.String, float64(1)},
+		{ql.Time, "yesterday"},
+		{ql.Blob, "!!"},
+		{ql.BigInt, json.Number("1.5")},
+		{ql.BigRat, "one third"},
+		{ql.Int64, "1e30"},
+		{ql.Int64, float64(1e30)},
+		{ql.Int64, "9223372036854775808"},
+		{ql.Uint64, "18446744073709551616"},
+		{ql.Int16, float64(40000)},
+		{ql.Uint32, "-1e3"},
+		{ql.Complex128, "1+2i"},
+	}
+	for _, v := range invalid {
+		if _, err := coerce(v.typ, v.src); err == nil {
+			t.Errorf("expected error for %s %v", v.typ, v.src)
+		}
+	}
+}
+
+func TestCRUD_validate(t *testing.T) {
+	db, err := ql.OpenMem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	_, _, err = db.Run(ql.NewRWCtx(), `
+	begin transaction;
+		create table profiles(
+			id int64,
+			age int64,
+		);
+		create table users(
+			id int64,
+			profiles_id int64,
+			score float64,
+			created_at time,
+		);
+	commit;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCrud(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := modelProps{
+		"score":      "1.5",
+		"created_at": "Mon Jan 2 15:04:05 2006",
+		"profile":    map[string]interface{}{"age": json.Number("30")},
+		"nickname":   "gernest",
+	}
+	err = c.validate("users", props, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props["score"] != 1.5 {
+		t.Errorf("expected 1.5 got %v", props["score"])
+	}
+	if p, _ := props.propProperty("profile"); p["age"] != int64(30) {
+		t.Errorf("expected 30 got %v", p["age"])
+	}
+	_, err = c.create("users", props)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.validate("users", modelProps{
+		"score":    "high",
+		"profile":  map[string]interface{}{"age": "old"},
+		"nickname": "gernest",
+	}, true)
+	v, ok := err.(*validationError)
+	if !ok {
+		t.Fatalf("expected validation error got %v", err)
+	}
+	for _, name := range []string{"score", "profile.age", "nickname"} {
+		if _, ok := v.fields[name]; !ok {
+			t.Errorf("expected %s to fail validation", name)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cznic/ql"
+)
+
+// Webhooks live in the current database next to the models, the __ prefix keeps
+// them out of the schema. Registering a new schema starts without webhooks.
+const webhooksDDL = `
+begin transaction;
+create table if not exists __webhooks (
+	model      string not null,
+	ops        string,
+	url        string not null,
+	secret     string,
+	created_at time);
+create table if not exists __webhook_deliveries (
+	webhook_id int64,
+	seq        int64,
+	model      string,
+	op         string,
+	record_id  int64,
+	attempt    int64,
+	status     int64,
+	error      string,
+	created_at time);
+commit;`
+
+const (
+	webhookAttempts       = 5
+	webhookDeliveryLimit  = 100
+	webhookSignature      = "X-Qlfu-Signature"
+	webhookEventHeader    = "X-Qlfu-Event"
+	webhookDeliveryHeader = "X-Qlfu-Delivery"
+)
+
+var webhookBackoff = time.Second
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+type webhook struct {
+	ID        int64     `json:"id"`
+	Model     string    `json:"model"`
+	Ops       []string  `json:"ops"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (h *webhook) wants(op string) bool {
+	if len(h.Ops) == 0 {
+		return true
+	}
+	for _, v := range h.Ops {
+		if v == op {
+			return true
+		}
+	}
+	return false
+}
+
+type webhookDelivery struct {
+	ID        int64     `json:"id"`
+	WebhookID int64     `json:"webhook_id"`
+	Seq       int64     `json:"seq"`
+	Model     string    `json:"model"`
+	Op        string    `json:"op"`
+	RecordID  int64     `json:"record_id"`
+	Attempt   int64     `json:"attempt"`
+	Status    int64     `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// webhookDispatcher queues every change for the webhooks that want it and
+// delivers the queue of each webhook in order, one event at a time.
+type webhookDispatcher struct {
+	sync   sync.Mutex
+	mu     sync.Mutex
+	db     *ql.DB
+	hooks  []*webhook
+	queues map[int64]*webhookQueue
+	wg     sync.WaitGroup
+}
+
+type webhookQueue struct {
+	hook    *webhook
+	events  []*change
+	running bool
+}
+
+func hasTable(db *ql.DB, name string) bool {
+	i, err := db.Info()
+	if err != nil {
+		return false
+	}
+	for _, v := range i.Tables {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func listWebhooks(db *ql.DB, model string) ([]*webhook, error) {
+	var o []*webhook
+	if !hasTable(db, "__webhooks") {
+		return o, nil
+	}
+	q := "select id(), model, ops, url, secret, created_at from __webhooks order by id();"
+	var args []interface{}
+	if model != "" {
+		q = "select id(), model, ops, url, secret, created_at from __webhooks where model == $1 order by id();"
+		args = append(args, model)
+	}
+	rs, _, err := db.Run(ql.NewRWCtx(), q, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rs {
+		err = r.Do(false, func(data []interface{}) (bool, error) {
+			h := &webhook{ID: data[0].(int64), Model: data[1].(string), URL: data[3].(string)}
+			if v, ok := data[2].(string); ok && v != "" {
+				h.Ops = strings.Split(v, ",")
+			}
+			h.Secret, _ = data[4].(string)
+			h.CreatedAt, _ = data[5].(time.Time)
+			o = append(o, h)
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+func (c *crud) newWebhook(h *webhook) error {
+	verr := &validationError{}
+	if _, ok := c.schema.tables[h.Model]; !ok {
+		verr.add("model", "unknown model")
+	}
+	for _, v := range h.Ops {
+		if v != changeCreate && v != changeUpdate && v != changeDelete {
+			verr.add("ops", fmt.Sprintf("expected %s, %s or %s got %s", changeCreate, changeUpdate, changeDelete, v))
+		}
+	}
+	if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		verr.add("url", "expected an absolute http or https url")
+	}
+	if verr.failed() {
+		return verr
+	}
+	if h.Secret == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		h.Secret = hex.EncodeToString(b)
+	}
+	_, _, err := c.db.Run(ql.NewRWCtx(), webhooksDDL)
+	if err != nil {
+		return err
+	}
+	h.CreatedAt = time.Now()
+	ctx := ql.NewRWCtx()
+	_, _, err = c.db.Run(ctx, `
+begin transaction;
+insert into __webhooks (model, ops, url, secret, created_at) values ($1, $2, $3, $4, $5);
+commit;`, h.Model, strings.Join(h.Ops, ","), h.URL, h.Secret, h.CreatedAt)
+	if err != nil {
+		return err
+	}
+	h.ID = ctx.LastInsertID
+	return nil
+}
+
+func (c *crud) deleteWebhook(id int64) error {
+	if !hasTable(c.db, "__webhooks") {
+		return errNotFound
+	}
+	ctx := ql.NewRWCtx()
+	_, _, err := c.db.Run(ctx, `
+begin transaction;
+delete from __webhooks where id() == $1;
+commit;`, id)
+	if err != nil {
+		return err
+	}
+	if ctx.RowsAffected == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func listDeliveries(db *ql.DB, webhookID int64, limit int) ([]*webhookDelivery, error) {
+	o := []*webhookDelivery{}
+	if !hasTable(db, "__webhook_deliveries") {
+		return o, nil
+	}
+	rs, _, err := db.Run(ql.NewRWCtx(), `
+select id(), webhook_id, seq, model, op, record_id, attempt, status, error, created_at
+from __webhook_deliveries where webhook_id == $1 order by id() desc limit $2;`, webhookID, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rs {
+		err = r.Do(false, func(data []interface{}) (bool, error) {
+			d := &webhookDelivery{
+				ID:        data[0].(int64),
+				WebhookID: data[1].(int64),
+				Seq:       data[2].(int64),
+				Model:     data[3].(string),
+				Op:        data[4].(string),
+				RecordID:  data[5].(int64),
+				Attempt:   data[6].(int64),
+				Status:    data[7].(int64),
+			}
+			d.Error, _ = data[8].(string)
+			d.CreatedAt, _ = data[9].(time.Time)
+			o = append(o, d)
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+func logDelivery(db *ql.DB, d *webhookDelivery) error {
+	_, _, err := db.Run(ql.NewRWCtx(), `
+begin transaction;
+insert into __webhook_deliveries (webhook_id, seq, model, op, record_id, attempt, status, error, created_at)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+commit;`, d.WebhookID, d.Seq, d.Model, d.Op, d.RecordID, d.Attempt, d.Status, d.Error, d.CreatedAt)
+	return err
+}
+
+func (a *api) syncWebhooks() {
+	d := &a.webhooks
+	d.sync.Lock()
+	defer d.sync.Unlock()
+	hooks, _ := listWebhooks(a.db, "")
+	keep := make(map[int64]bool)
+	for _, h := range hooks {
+		keep[h.ID] = true
+	}
+	d.mu.Lock()
+	if d.db != a.db {
+		keep = nil
+	}
+	for id, q := range d.queues {
+		if !keep[id] {
+			q.events = nil
+			delete(d.queues, id)
+		}
+	}
+	d.db = a.db
+	d.hooks = hooks
+	d.mu.Unlock()
+	if len(hooks) > 0 {
+		a.changes.follow(d.enqueue)
+	} else {
+		a.changes.follow(nil)
+	}
+}
+
+func (d *webhookDispatcher) enqueue(e *change) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, h := range d.hooks {
+		if h.Model != e.Model || !h.wants(e.Op) {
+			continue
+		}
+		if d.queues == nil {
+			d.queues = make(map[int64]*webhookQueue)
+		}
+		q, ok := d.queues[h.ID]
+		if !ok {
+			q = &webhookQueue{hook: h}
+			d.queues[h.ID] = q
+		}
+		q.events = append(q.events, e)
+		if !q.running {
+			q.running = true
+			d.wg.Add(1)
+			go d.work(d.db, q)
+		}
+	}
+}
+
+func (d *webhookDispatcher) work(db *ql.DB, q *webhookQueue) {
+	defer d.wg.Done()
+	for {
+		d.mu.Lock()
+		if len(q.events) == 0 {
+			q.running = false
+			d.mu.Unlock()
+			return
+		}
+		e := q.events[0]
+		q.events[0] = nil
+		q.events = q.events[1:]
+		d.mu.Unlock()
+		deliver(db, q.hook, e)
+	}
+}
+
+func deliver(db *ql.DB, h *webhook, e *change) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	backoff := webhookBackoff
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		status, err := postWebhook(h, e, body)
+		l := &webhookDelivery{
+			WebhookID: h.ID,
+			Seq:       e.Seq,
+			Model:     e.Model,
+			Op:        e.Op,
+			RecordID:  e.ID,
+			Attempt:   int64(attempt),
+			Status:    int64(status),
+			CreatedAt: time.Now(),
+		}
+		if err != nil {
+			l.Error = err.Error()
+		}
+		_ = logDelivery(db, l)
+		if err == nil {
+			return
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+func signWebhook(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	_, _ = m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+func postWebhook(h *webhook, e *change, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, e.Op)
+	req.Header.Set(webhookDeliveryHeader, fmt.Sprintf("%d-%d", h.ID, e.Seq))
+	req.Header.Set(webhookSignature, signWebhook(h.Secret, body))
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func (a *api) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := listWebhooks(a.db, r.URL.Query().Get("model"))
+	if err != nil {
+		jsonErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, h := range hooks {
+		h.Secret = ""
+	}
+	if hooks == nil {
+		hooks = []*webhook{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonRes(w, hooks)
+}
+
+func (a *api) createWebhook(w http.ResponseWriter, r *http.Request) {
+	h := &webhook{}
+	if err := json.NewDecoder(r.Body).Decode(h); err != nil {
+		jsonErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := a.c.newWebhook(h); err != nil {
+		jsonErr(w, err, crudErrCode(err))
+		return
+	}
+	a.syncWebhooks()
+	w.Header().Set("Content-Type", "application/json")
+	jsonRes(w, h)
+}
+
+func (a *api) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := a.c.deleteWebhook(id); err != nil {
+		jsonErr(w, err, crudErrCode(err))
+		return
+	}
+	a.syncWebhooks()
+	jsonOk(w)
+}
+
+func (a *api) webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r)
+	if err != nil {
+		jsonErr(w, err, http.StatusBadRequest)
+		return
+	}
+	limit := webhookDeliveryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			verr := &validationError{}
+			verr.add("limit", "expected a positive integer")
+			jsonErr(w, verr, crudErrCode(verr))
+			return
+		}
+	}
+	o, err := listDeliveries(a.db, id, limit)
+	if err != nil {
+		jsonErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonRes(w, o)
+}
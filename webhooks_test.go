@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+func TestAPI_webhooks(t *testing.T) {
+	backoff := webhookBackoff
+	webhookBackoff = time.Millisecond
+	defer func() {
+		webhookBackoff = backoff
+	}()
+	var mu sync.Mutex
+	var calls int
+	got := make(chan testDelivery, 10)
+	rcv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		got <- testDelivery{header: r.Header, body: b}
+	}))
+	defer rcv.Close()
+
+	a, ts, done := testChangesAPI(t)
+	defer done()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do("POST", "/_webhooks", `{"model":"posts","ops":["upsert"],"url":"ftp://example.com"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected %d got %d %s", http.StatusUnprocessableEntity, w.Code, w.Body)
+	}
+	for _, k := range []string{"model", "ops", "url"} {
+		if !strings.Contains(w.Body.String(), `"`+k+`"`) {
+			t.Errorf("expected an error for %s got %s", k, w.Body)
+		}
+	}
+
+	w = do("POST", "/_webhooks", `{"model":"users","ops":["create"],"url":"`+rcv.URL+`","secret":"s3cret"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	var h webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.ID == 0 || h.Secret != "s3cret" {
+		t.Fatalf("unexpected webhook %+v", h)
+	}
+	w = do("POST", "/_webhooks", `{"model":"users","ops":["delete"],"url":"`+rcv.URL+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	var other webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &other); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Secret) != 32 {
+		t.Errorf("expected a generated secret got %q", other.Secret)
+	}
+
+	w = do("GET", "/_webhooks?model=users", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "s3cret") {
+		t.Fatalf("expected webhooks without secrets got %d %s", w.Code, w.Body)
+	}
+	var hooks []webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &hooks); err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 2 {
+		t.Fatalf("expected 2 webhooks got %d", len(hooks))
+	}
+	if w = do("GET", "/schema", ""); strings.Contains(w.Body.String(), "__webhooks") {
+		t.Errorf("expected the webhooks table to be hidden got %s", w.Body)
+	}
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(testWrite(t, ts, "POST", "/v1/users", `{"username":"gernest"}`), &user); err != nil {
+		t.Fatal(err)
+	}
+	testWrite(t, ts, "PUT", fmt.Sprintf("/v1/users/%d", user.ID), `{"email":"g@example.com"}`)
+
+	var d testDelivery
+	select {
+	case d = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the webhook")
+	}
+	a.webhooks.wg.Wait()
+	if sig := d.header.Get(webhookSignature); sig != signWebhook("s3cret", d.body) {
+		t.Errorf("expected signature %s got %s", signWebhook("s3cret", d.body), sig)
+	}
+	if d.header.Get(webhookEventHeader) != changeCreate {
+		t.Errorf("expected a %s event got %s", changeCreate, d.header.Get(webhookEventHeader))
+	}
+	var c change
+	if err := json.Unmarshal(d.body, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Model != "users" || c.ID != user.ID || c.Record["username"] != "gernest" {
+		t.Errorf("unexpected payload %s", d.body)
+	}
+	select {
+	case d = <-got:
+		t.Errorf("expected a single delivery got %s", d.body)
+	default:
+	}
+
+	w = do("GET", fmt.Sprintf("/_webhooks/%d/deliveries", h.ID), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	var log []webhookDelivery
+	if err := json.Unmarshal(w.Body.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 2 {
+		t.Fatalf("expected 2 attempts got %s", w.Body)
+	}
+	if log[0].Attempt != 2 || log[0].Status != http.StatusOK || log[0].Error != "" {
+		t.Errorf("expected a successful second attempt got %+v", log[0])
+	}
+	if log[1].Attempt != 1 || log[1].Status != http.StatusInternalServerError || log[1].Error == "" {
+		t.Errorf("expected a failed first attempt got %+v", log[1])
+	}
+
+	for _, id := range []int64{h.ID, other.ID} {
+		if w = do("DELETE", fmt.Sprintf("/_webhooks/%d", id), ""); w.Code != http.StatusOK {
+			t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+		}
+	}
+	if w = do("DELETE", fmt.Sprintf("/_webhooks/%d", h.ID), ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected %d got %d %s", http.StatusNotFound, w.Code, w.Body)
+	}
+	if a.changes.active() {
+		t.Error("expected the dispatcher to stop without webhooks")
+	}
+}
+
+func TestAPI_webhooksBurst(t *testing.T) {
+	var mu sync.Mutex
+	var seen []int64
+	rcv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var c change
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		seen = append(seen, c.ID)
+		mu.Unlock()
+	}))
+	defer rcv.Close()
+	a, _, done := testChangesAPI(t)
+	defer done()
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest("POST", "/_webhooks", strings.NewReader(`{"model":"users","url":"`+rcv.URL+`"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d %s", http.StatusOK, w.Code, w.Body)
+	}
+	n := changeBuffer + 50
+	var ids []int64
+	for i := 0; i < n; i++ {
+		o, err := a.c.create("users", modelProps{"username": fmt.Sprint(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, o["id"].(int64))
+	}
+	a.webhooks.wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != n {
+		t.Fatalf("expected %d deliveries got %d", n, len(seen))
+	}
+	for i := range ids {
+		if seen[i] != ids[i] {
+			t.Fatalf("expected delivery %d to be %d got %d", i, ids[i], seen[i])
+		}
+	}
+}